@@ -0,0 +1,235 @@
+package otsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kr/pretty"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/label"
+)
+
+var (
+	argTypesLbl  = label.Key("db.statement.arg_types")
+	argIndexLbl  = label.Key("db.statement.arg_index")
+	argTypeLbl   = label.Key("db.statement.arg_type")
+	namedArgLbl  = label.Key("db.params.named")
+	argsBytesLbl = label.Key("db.statement.args_bytes")
+)
+
+// ArgsMode controls how the arguments of calls are recorded.
+type ArgsMode int
+
+const (
+	// ArgsValues records the formatted argument values. The default.
+	ArgsValues ArgsMode = iota
+	// ArgsOmitted doesn't record arguments.
+	ArgsOmitted
+	// ArgsTypes records the Go types of the arguments, e.g.
+	// [string, int64, []uint8], but not their values.
+	ArgsTypes
+)
+
+// appendArgs appends the attribute recording args to attrs according to
+// the args mode.
+func (cfg *config) appendArgs(attrs []label.KeyValue, span trace.Span, args interface{}) []label.KeyValue {
+	switch cfg.argsMode {
+	case ArgsValues:
+		attrs = append(attrs, cfg.argsKey.String(cfg.maybeFormatArgs(span, args)))
+	case ArgsTypes:
+		if span.IsRecording() {
+			attrs = append(attrs, argTypesLbl.String(formatArgTypes(args)))
+		}
+	}
+	return attrs
+}
+
+// hasNamedArgs reports whether any of args is passed by name.
+func hasNamedArgs(args interface{}) bool {
+	if named, ok := args.([]driver.NamedValue); ok {
+		for _, nv := range named {
+			if nv.Name != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// maxSizedArgs caps how many arguments of a call are sized, so that calls
+// with huge numbers of them don't pay for it.
+const maxSizedArgs = 1000
+
+// appendArgsBytes appends an estimate of the bytes args weigh when sent,
+// when enabled and the span is recording.
+func (cfg *config) appendArgsBytes(attrs []label.KeyValue, span trace.Span, args interface{}) []label.KeyValue {
+	if !cfg.argsBytes || !span.IsRecording() {
+		return attrs
+	}
+	var n int64
+	switch a := args.(type) {
+	case []driver.NamedValue:
+		for i := 0; i < len(a) && i < maxSizedArgs; i++ {
+			n += argSize(a[i].Value)
+		}
+	case []driver.Value:
+		for i := 0; i < len(a) && i < maxSizedArgs; i++ {
+			n += argSize(a[i])
+		}
+	}
+	return append(attrs, argsBytesLbl.Int64(n))
+}
+
+// argSize estimates the bytes v weighs when sent.
+func argSize(v driver.Value) int64 {
+	switch v := v.(type) {
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case int64, float64, time.Time:
+		return 8
+	case bool:
+		return 1
+	}
+	return 0
+}
+
+// maybeFormatArgs formats args only when span is recording, since
+// formatting is costly and its result would be dropped otherwise. Every
+// call site must go through it rather than formatArgs.
+func (cfg *config) maybeFormatArgs(span trace.Span, args interface{}) string {
+	if !span.IsRecording() {
+		return ""
+	}
+	return cfg.formatArgs(args)
+}
+
+// formatArgs renders the arguments of a call, either []driver.Value or
+// []driver.NamedValue, for the args attribute.
+func (cfg *config) formatArgs(args interface{}) string {
+	more := 0
+	if max := cfg.maxArgsCount; max > 0 {
+		switch a := args.(type) {
+		case []driver.NamedValue:
+			if len(a) > max {
+				args, more = a[:max], len(a)-max
+			}
+		case []driver.Value:
+			if len(a) > max {
+				args, more = a[:max], len(a)-max
+			}
+		}
+	}
+
+	s := pretty.Sprint(cfg.formatArgValues(args))
+	if more > 0 {
+		s += fmt.Sprintf("...(%d more)", more)
+	}
+	return truncate(s, cfg.maxArgsLength)
+}
+
+// redactedArg replaces the values of redacted parameters.
+const redactedArg = "xxxxx"
+
+// formatArgValues returns a copy of args with the values of redacted
+// parameters masked and the values the arg value formatter renders replaced
+// by their rendering, or args itself when there's nothing to do.
+func (cfg *config) formatArgValues(args interface{}) interface{} {
+	if cfg.argValueFormat == nil && len(cfg.redactedParams) == 0 {
+		return args
+	}
+	switch a := args.(type) {
+	case []driver.NamedValue:
+		formatted := make([]driver.NamedValue, len(a))
+		for i, nv := range a {
+			if nv.Name != "" && cfg.redactedParams[strings.ToLower(nv.Name)] {
+				nv.Value = redactedArg
+			} else {
+				nv.Value = cfg.formatArgValue(nv.Value)
+			}
+			formatted[i] = nv
+		}
+		return formatted
+	case []driver.Value:
+		formatted := make([]driver.Value, len(a))
+		for i, v := range a {
+			formatted[i] = cfg.formatArgValue(v)
+		}
+		return formatted
+	}
+	return args
+}
+
+func (cfg *config) formatArgValue(v driver.Value) driver.Value {
+	if cfg.argValueFormat != nil {
+		if s, ok := cfg.argValueFormat(v); ok {
+			return s
+		}
+	}
+	return v
+}
+
+// truncate cuts s to at most max bytes without splitting a rune, marking
+// the cut with an ellipsis. A max of 0 means no limit.
+func truncate(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max] + "..."
+}
+
+func formatArgTypes(args interface{}) string {
+	var types []string
+	switch a := args.(type) {
+	case []driver.NamedValue:
+		types = make([]string, len(a))
+		for i, nv := range a {
+			types[i] = fmt.Sprintf("%T", nv.Value)
+		}
+	case []driver.Value:
+		types = make([]string, len(a))
+		for i, v := range a {
+			types[i] = fmt.Sprintf("%T", v)
+		}
+	}
+	return "[" + strings.Join(types, ", ") + "]"
+}
+
+// checkedNamedValue returns the error of the driver checking an argument,
+// with the argument it's about, and traces it if enabled.
+//
+// database/sql checks the arguments before calling into the exec or query
+// of the driver, so there's no span of the call yet: the rejection gets a
+// span of its own, parented to the context associated with the conn if any.
+func (cfg *config) checkedNamedValue(info *connInfo, nv *driver.NamedValue, err error) error {
+	// database/sql compares these sentinels by identity: never wrap them.
+	if err == nil || err == driver.ErrSkip || err == driver.ErrRemoveArgument {
+		return err
+	}
+	if cfg.argsMode == ArgsOmitted {
+		err = errors.Wrapf(err, "argument %d", nv.Ordinal-1)
+	} else {
+		err = errors.Wrapf(err, "argument %d (%T)", nv.Ordinal-1, nv.Value)
+	}
+	if !cfg.traceArgRejections {
+		return err
+	}
+
+	ctx, span := cfg.startSpan(info.spanContext(context.Background()), "sql-check-named-value", info.spanOption())
+	span.AddEvent(ctx, "sql-arg-rejected",
+		argIndexLbl.Int(nv.Ordinal-1),
+		argTypeLbl.String(fmt.Sprintf("%T", nv.Value)),
+	)
+	cfg.recordError(ctx, span, err)
+	cfg.endSpan(span, err)
+	return err
+}
@@ -0,0 +1,99 @@
+package otsql_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aybabtme/otsql"
+	"github.com/aybabtme/otsql/otsqltest"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/api/trace/tracetest"
+)
+
+// countingFormatter is an arg value formatter counting its calls.
+type countingFormatter struct {
+	calls int64
+}
+
+func (f *countingFormatter) format(v driver.Value) (string, bool) {
+	atomic.AddInt64(&f.calls, 1)
+	return "", false
+}
+
+func openFormatterDB(tb testing.TB, tracer trace.Tracer, f *countingFormatter) *sql.DB {
+	name, err := otsql.WrapDriver("formatter-"+strconv.FormatInt(atomic.AddInt64(&testDrivers, 1), 10),
+		&otsqltest.Driver{}, tracer, otsql.WithArgValueFormatter(f.format))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	db, err := sql.Open(name, "test")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestArgsNotFormattedWhenNotRecording(t *testing.T) {
+	tests := []struct {
+		name      string
+		tracer    trace.Tracer
+		formatted bool
+	}{
+		{"recording", tracetest.NewProvider().Tracer("test"), true},
+		{"not recording", trace.NoopTracer{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &countingFormatter{}
+			db := openFormatterDB(t, tt.tracer, f)
+			ctx := context.Background()
+			if _, err := db.ExecContext(ctx, "UPDATE t SET name = ? WHERE id = ?", "x", 1); err != nil {
+				t.Fatal(err)
+			}
+			rows, err := db.QueryContext(ctx, "SELECT name FROM t WHERE id = ?", 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows.Close()
+			stmt, err := db.Prepare("UPDATE t SET name = ? WHERE id = ?")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stmt.Close()
+			if _, err := stmt.Exec("x", 1); err != nil {
+				t.Fatal(err)
+			}
+
+			if calls := atomic.LoadInt64(&f.calls); (calls > 0) != tt.formatted {
+				t.Errorf("formatter called %d times, want formatting %v", calls, tt.formatted)
+			}
+		})
+	}
+}
+
+func BenchmarkArgsFormatting(b *testing.B) {
+	for _, bc := range []struct {
+		name   string
+		tracer trace.Tracer
+	}{
+		{"recording", tracetest.NewProvider().Tracer("bench")},
+		{"not recording", trace.NoopTracer{}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			db := openFormatterDB(b, bc.tracer, &countingFormatter{})
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := db.ExecContext(ctx, "INSERT INTO t VALUES (?, ?, ?, ?)", 1, "a", 2.5, []byte("b")); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package otsql
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/label"
+)
+
+var (
+	statementCountLbl = label.Key("db.statement.count")
+	multiStatementLbl = label.Key("db.multistatement")
+	sizeClassLbl      = label.Key("db.statement.size_class")
+)
+
+// setCallAttrs records the statement of a call, its hash, its arguments and
+// the extra attributes of its caller when the span starts, in a single call
+// into the span. args are nil for prepares, which have no arguments to
+// record.
+func (cfg *config) setCallAttrs(ctx context.Context, span trace.Span, query string, hash *stmtHash, args interface{}, extra ...label.KeyValue) {
+	rawArgs := args
+	args = cfg.capturedArgs(query, args)
+	full, withArgs := fullStatement(ctx)
+	full = full && span.IsRecording()
+	var attrs []label.KeyValue
+	if full {
+		// In place of the statement, and the args if asked for, in any
+		// other form.
+		attrs = cfg.appendFullStatement(attrs, query, rawArgs, withArgs)
+		cfg.auditFullStatement(ctx, span, query, withArgs)
+		if withArgs {
+			args = nil
+		}
+	} else if !cfg.statementOnErrorOnly {
+		attrs = cfg.appendStatement(attrs, span, query, args)
+	}
+	if cfg.multiStatements && span.IsRecording() {
+		if n := cfg.tokenizer.countStatements(query); n > 1 {
+			attrs = append(attrs, statementCountLbl.Int(n), multiStatementLbl.Bool(true))
+		}
+	}
+	if cfg.placeholderCount && span.IsRecording() {
+		attrs = append(attrs, placeholderCountLbl.Int(countPlaceholders(cfg.tokenizer.tokenize(query))))
+	}
+	if rawArgs != nil && span.IsRecording() {
+		attrs = append(attrs, namedArgLbl.Bool(hasNamedArgs(rawArgs)))
+	}
+	if hash != nil && span.IsRecording() {
+		attrs = append(attrs, queryHashLbl.String(hash.get()))
+	}
+	if args != nil {
+		attrs = cfg.appendArgs(attrs, span, args)
+	}
+	attrs = append(attrs, extra...)
+	if len(attrs) > 0 {
+		span.SetAttributes(attrs...)
+	}
+}
+
+// setQueryOnError records the statement of a call that failed, when it
+// wasn't already recorded at start.
+func (cfg *config) setQueryOnError(ctx context.Context, span trace.Span, query string, args interface{}) {
+	if full, _ := fullStatement(ctx); full || !cfg.statementOnErrorOnly {
+		return
+	}
+	span.SetAttributes(cfg.appendStatement(nil, span, query, cfg.capturedArgs(query, args))...)
+}
+
+// capturedArgs returns the args of query to record, nil when they must not
+// be.
+func (cfg *config) capturedArgs(query string, args interface{}) interface{} {
+	if cfg.argsReadsOnly && args != nil {
+		switch cfg.tokenizer.sqlVerb(query) {
+		case "SELECT", "SHOW":
+		default:
+			// Writes, and statements whose verb is unknown.
+			return nil
+		}
+	}
+	return args
+}
+
+// appendStatement appends the statement to record for query to attrs, and
+// its size class. When it's interpolated, the placeholder style it was
+// interpolated with is appended too. With dual statements, the statement
+// goes under db.statement.raw, and its obfuscated form under db.statement.
+// Obfuscated statements go in their obfuscated form only.
+func (cfg *config) appendStatement(attrs []label.KeyValue, span trace.Span, query string, args interface{}) []label.KeyValue {
+	class := sizeClass(len(query))
+	if cfg.obfuscate {
+		// Neither the literals nor the arguments make it to the span.
+		if span.IsRecording() {
+			attrs = append(attrs, queryLbl.String(truncate(obfuscate(cfg.tokenizer, query), cfg.maxStatementLength)))
+		}
+		return append(attrs, sizeClassLbl.String(class))
+	}
+	if cfg.dualStatement && span.IsRecording() {
+		attrs = append(attrs, queryLbl.String(truncate(obfuscate(cfg.tokenizer, query), cfg.maxStatementLength)))
+	}
+	if cfg.interpolate && cfg.argsMode == ArgsValues && span.IsRecording() {
+		stmt, style := interpolate(cfg.tokenizer, query, cfg.formatArgValues(args))
+		if style != styleUnknown {
+			attrs = append(attrs, placeholderStyleLbl.String(string(style)))
+		}
+		query = stmt
+	}
+	key := queryLbl
+	if cfg.dualStatement && span.IsRecording() {
+		key = rawStatementLbl
+	}
+	return append(attrs,
+		key.String(truncate(query, cfg.maxStatementLength)),
+		sizeClassLbl.String(class),
+	)
+}
+
+// sizeClass buckets the length of a statement, as a low-cardinality way
+// to spot giant generated statements.
+func sizeClass(n int) string {
+	switch {
+	case n <= 256:
+		return "small"
+	case n <= 4096:
+		return "medium"
+	default:
+		return "large"
+	}
+}
@@ -0,0 +1,106 @@
+package otsql_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/aybabtme/otsql"
+	"github.com/aybabtme/otsql/otsqltest"
+)
+
+// numInputDriver opens conns whose stmts expect n arguments.
+type numInputDriver struct {
+	otsqltest.Driver
+	n int
+}
+
+func (d *numInputDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	return numInputConn{conn, d.n}, err
+}
+
+type numInputConn struct {
+	driver.Conn
+	n int
+}
+
+func (c numInputConn) Prepare(query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.Prepare(query)
+	return numInputStmt{stmt, c.n}, err
+}
+
+type numInputStmt struct {
+	driver.Stmt
+	n int
+}
+
+func (s numInputStmt) NumInput() int { return s.n }
+
+func TestCallAttrs(t *testing.T) {
+	const query = "UPDATE t SET name = ? WHERE id = ?"
+
+	assertAttrs := func(t *testing.T, span otsqltest.Span, want map[string]interface{}) {
+		t.Helper()
+		for k, v := range want {
+			if got := span.Attr(k); got != v {
+				t.Errorf("%s = %#v, want %#v", k, got, v)
+			}
+		}
+	}
+
+	t.Run("conn exec", func(t *testing.T) {
+		db, rec := openTestDB(t, &otsqltest.Driver{}, otsql.WithArgsBytes(), otsql.WithQueryHash())
+		if _, err := db.ExecContext(context.Background(), query, "abc", 1); err != nil {
+			t.Fatal(err)
+		}
+		spans := rec.Named("sql-conn-exec")
+		if len(spans) != 1 {
+			t.Fatalf("got %d sql-conn-exec spans, want 1", len(spans))
+		}
+		assertAttrs(t, spans[0], map[string]interface{}{
+			"query":                   query,
+			"db.params.named":         false,
+			"db.statement.args_bytes": int64(3 + 8),
+			"db.arg_count_mismatch":   nil,
+		})
+		if spans[0].Attr("db.statement.hash") == nil {
+			t.Error("db.statement.hash is missing")
+		}
+	})
+
+	t.Run("stmt exec with mismatched args", func(t *testing.T) {
+		db, rec := openTestDB(t, &numInputDriver{n: 2}, otsql.WithArgsBytes())
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		// database/sql checks the arg count itself, so the stmt is used
+		// directly to reach it with the wrong one.
+		err = conn.Raw(func(dc interface{}) error {
+			stmt, err := dc.(driver.Conn).Prepare(query)
+			if err != nil {
+				return err
+			}
+			defer stmt.Close()
+			_, err = stmt.Exec([]driver.Value{"abc"})
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		spans := rec.Named("sql-stmt-exec")
+		if len(spans) != 1 {
+			t.Fatalf("got %d sql-stmt-exec spans, want 1", len(spans))
+		}
+		assertAttrs(t, spans[0], map[string]interface{}{
+			"query":                   query,
+			"db.params.named":         false,
+			"db.statement.args_bytes": int64(3),
+			"db.arg_count_mismatch":   true,
+			"db.arg_count.expected":   int64(2),
+			"db.arg_count.actual":     int64(1),
+		})
+	})
+}
@@ -0,0 +1,118 @@
+package otsql_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/aybabtme/otsql"
+	"github.com/aybabtme/otsql/otsqltest"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/api/trace/tracetest"
+)
+
+var benchDriver = &otsqltest.Driver{
+	Columns: []string{"id", "name"},
+	Rows: [][]driver.Value{
+		{int64(1), "a"}, {int64(2), "b"}, {int64(3), "c"}, {int64(4), "d"},
+		{int64(5), "e"}, {int64(6), "f"}, {int64(7), "g"}, {int64(8), "h"},
+	},
+}
+
+func init() {
+	sql.Register("bench-disabled", benchDriver)
+	// The test tracer records its spans without keeping them around.
+	if _, err := otsql.WrapDriver("bench-sampled", benchDriver, tracetest.NewProvider().Tracer("bench")); err != nil {
+		panic(err)
+	}
+	// The noop tracer starts spans that aren't recording, like a sampler
+	// dropping the trace does.
+	if _, err := otsql.WrapDriver("bench-unsampled", benchDriver, trace.NoopTracer{}); err != nil {
+		panic(err)
+	}
+}
+
+// benchConfigs are the configurations each benchmark runs under: traced
+// and recorded, traced but not recorded, and not traced at all.
+var benchConfigs = []struct {
+	name   string
+	driver string
+}{
+	{"sampled", "traced-bench-sampled"},
+	{"unsampled", "traced-bench-unsampled"},
+	{"disabled", "bench-disabled"},
+}
+
+func openBenchDB(b *testing.B, driverName string) *sql.DB {
+	db, err := sql.Open(driverName, "bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+	return db
+}
+
+func BenchmarkExecContext(b *testing.B) {
+	for _, bc := range benchConfigs {
+		b.Run(bc.name, func(b *testing.B) {
+			db := openBenchDB(b, bc.driver)
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := db.ExecContext(ctx, "UPDATE t SET name = ? WHERE id = ?", "x", 1); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkQueryContext(b *testing.B) {
+	for _, bc := range benchConfigs {
+		b.Run(bc.name, func(b *testing.B) {
+			db := openBenchDB(b, bc.driver)
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rows, err := db.QueryContext(ctx, "SELECT id, name FROM t WHERE id = ?", 1)
+				if err != nil {
+					b.Fatal(err)
+				}
+				rows.Close()
+			}
+		})
+	}
+}
+
+func BenchmarkRowsIteration(b *testing.B) {
+	for _, bc := range benchConfigs {
+		b.Run(bc.name, func(b *testing.B) {
+			db := openBenchDB(b, bc.driver)
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rows, err := db.QueryContext(ctx, "SELECT id, name FROM t")
+				if err != nil {
+					b.Fatal(err)
+				}
+				var (
+					id   int64
+					name string
+				)
+				for rows.Next() {
+					if err := rows.Scan(&id, &name); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if err := rows.Err(); err != nil {
+					b.Fatal(err)
+				}
+				rows.Close()
+			}
+		})
+	}
+}
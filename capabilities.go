@@ -0,0 +1,57 @@
+package otsql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+
+	"github.com/pkg/errors"
+)
+
+// Capabilities lists the optional database/sql/driver interfaces a driver
+// implements. Without ExecerContext and QueryerContext, or ConnPrepareContext
+// and the statement counterparts, calls can't be canceled once made.
+type Capabilities struct {
+	DriverContext      bool
+	ConnBeginTx        bool
+	ConnPrepareContext bool
+	ExecerContext      bool
+	QueryerContext     bool
+	Pinger             bool
+	SessionResetter    bool
+	Validator          bool
+	NamedValueChecker  bool
+}
+
+// DriverCapabilities reports the capabilities of the driver registered as
+// name, e.g. "postgres" or a traced driver, in which case those of the
+// driver it wraps are reported. The connection-level interfaces are checked
+// on a connection opened with dsn, which is closed before returning.
+func DriverCapabilities(name, dsn string) (Capabilities, error) {
+	db, err := sql.Open(name, dsn)
+	if err != nil {
+		return Capabilities{}, errors.Wrap(err, "looking up driver")
+	}
+	defer db.Close()
+
+	d := db.Driver()
+	if wd, ok := d.(wrappedDriver); ok {
+		d = wd.parent
+	}
+	conn, err := d.Open(dsn)
+	if err != nil {
+		return Capabilities{}, errors.Wrap(err, "opening connection")
+	}
+	defer conn.Close()
+
+	var caps Capabilities
+	_, caps.DriverContext = d.(driver.DriverContext)
+	_, caps.ConnBeginTx = conn.(driver.ConnBeginTx)
+	_, caps.ConnPrepareContext = conn.(driver.ConnPrepareContext)
+	_, caps.ExecerContext = conn.(driver.ExecerContext)
+	_, caps.QueryerContext = conn.(driver.QueryerContext)
+	_, caps.Pinger = conn.(driver.Pinger)
+	_, caps.SessionResetter = conn.(driver.SessionResetter)
+	_, caps.Validator = conn.(driver.Validator)
+	_, caps.NamedValueChecker = conn.(driver.NamedValueChecker)
+	return caps, nil
+}
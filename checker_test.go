@@ -0,0 +1,42 @@
+package otsql_test
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/aybabtme/otsql/otsqltest"
+)
+
+// checkerDriver opens conns accepting []string arguments, like pgx does
+// for arrays, while their stmts have no checker of their own.
+type checkerDriver struct {
+	otsqltest.Driver
+}
+
+func (d *checkerDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	return checkerConn{conn}, err
+}
+
+type checkerConn struct {
+	driver.Conn
+}
+
+func (checkerConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if _, ok := nv.Value.([]string); ok {
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+func TestStmtFallsBackToConnChecker(t *testing.T) {
+	db, _ := openTestDB(t, &checkerDriver{})
+	stmt, err := db.Prepare("UPDATE t SET tags = ? WHERE id = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if _, err := stmt.Exec([]string{"a", "b"}, 1); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,36 @@
+package otsql
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/label"
+)
+
+var circuitStateLbl = label.Key("db.circuit.state")
+
+type circuitStateKey struct{}
+
+// WithCircuitState returns a context carrying the state of the circuit
+// breaker fronting the DB, e.g. "closed" or "half-open". The state is
+// recorded on every span started under the context.
+func WithCircuitState(ctx context.Context, state string) context.Context {
+	return context.WithValue(ctx, circuitStateKey{}, state)
+}
+
+func circuitStateFromContext(ctx context.Context) (string, bool) {
+	state, ok := ctx.Value(circuitStateKey{}).(string)
+	return state, ok
+}
+
+// RecordShortCircuit adds an event to the span of ctx for a DB call the
+// circuit breaker didn't let through. Such calls never reach the driver,
+// so the breaker has to report them, to explain the gaps in DB traffic
+// while it's open.
+func RecordShortCircuit(ctx context.Context) {
+	var attrs []label.KeyValue
+	if state, ok := circuitStateFromContext(ctx); ok {
+		attrs = append(attrs, circuitStateLbl.String(state))
+	}
+	trace.SpanFromContext(ctx).AddEvent(ctx, "sql-short-circuited", attrs...)
+}
@@ -0,0 +1,45 @@
+package otsql
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// wrappedConnector opens the connections of a traced driver with the
+// connector of the driver it wraps, when it has one, so that the DSN is
+// parsed once rather than on every open.
+type wrappedConnector struct {
+	driver wrappedDriver
+	name   string
+	parent driver.Connector
+}
+
+// OpenConnector implements driver.DriverContext. Drivers that don't
+// implement it themselves open their connections with Open, as
+// database/sql would.
+func (d wrappedDriver) OpenConnector(name string) (driver.Connector, error) {
+	c := wrappedConnector{driver: d, name: name}
+	if dc, ok := d.parent.(driver.DriverContext); ok {
+		parent, err := dc.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+		c.parent = parent
+	}
+	return c, nil
+}
+
+// Connect implements driver.Connector.
+func (c wrappedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.open(ctx, c.name, func(ctx context.Context) (driver.Conn, error) {
+		if c.parent == nil {
+			return c.driver.parent.Open(c.name)
+		}
+		return c.parent.Connect(ctx)
+	})
+}
+
+// Driver implements driver.Connector.
+func (c wrappedConnector) Driver() driver.Driver {
+	return c.driver
+}
@@ -0,0 +1,66 @@
+package otsql_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aybabtme/otsql/otsqltest"
+)
+
+// connectorDriver implements driver.DriverContext, counting how its
+// connections are opened.
+type connectorDriver struct {
+	otsqltest.Driver
+	opens, connectors, connects int64
+}
+
+func (d *connectorDriver) Open(name string) (driver.Conn, error) {
+	atomic.AddInt64(&d.opens, 1)
+	return d.Driver.Open(name)
+}
+
+func (d *connectorDriver) OpenConnector(name string) (driver.Connector, error) {
+	atomic.AddInt64(&d.connectors, 1)
+	return connector{d: d, name: name}, nil
+}
+
+type connector struct {
+	d    *connectorDriver
+	name string
+}
+
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
+	atomic.AddInt64(&c.d.connects, 1)
+	return c.d.Driver.Open(c.name)
+}
+
+func (c connector) Driver() driver.Driver { return c.d }
+
+func TestDriverContextParentOpensWithConnector(t *testing.T) {
+	drv := &connectorDriver{}
+	db, rec := openTestDB(t, drv)
+	db.SetMaxOpenConns(1)
+	for i := 0; i < 3; i++ {
+		if _, err := db.ExecContext(context.Background(), "UPDATE t SET name = 'x'"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if drv.connectors != 1 {
+		t.Errorf("got %d connectors opened, want 1", drv.connectors)
+	}
+	if drv.connects != 1 {
+		t.Errorf("got %d connects, want 1", drv.connects)
+	}
+	if drv.opens != 0 {
+		t.Errorf("got %d opens, want 0", drv.opens)
+	}
+	if spans := rec.Named("sql-conn-open"); len(spans) != 1 {
+		t.Errorf("got %d sql-conn-open spans, want 1", len(spans))
+	}
+	if spans := rec.Named("sql-conn-exec"); len(spans) != 3 {
+		t.Errorf("got %d sql-conn-exec spans, want 3", len(spans))
+	}
+}
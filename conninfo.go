@@ -0,0 +1,199 @@
+package otsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"time"
+
+	"github.com/aybabtme/otsql/dsn"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/semconv"
+)
+
+var (
+	connPinnedLbl    = label.Key("db.connection.pinned")
+	inTransactionLbl = label.Key("db.in_transaction")
+	connNewLbl       = label.Key("db.connection.new")
+	connAgeLbl       = label.Key("db.connection.age_ms")
+)
+
+// connInfo is the state of a conn, shared with the statements and
+// transactions created from it.
+type connInfo struct {
+	explain *explainer
+	attrs   []label.KeyValue
+	// opened is when the conn was opened, set when its age is recorded.
+	opened time.Time
+
+	// prepared counts the statements prepared on the conn and not closed
+	// yet. Accessed atomically.
+	prepared int64
+
+	mu     sync.Mutex
+	ctx    context.Context
+	pinned bool
+	inTx   bool
+	// fresh is whether the conn was just opened and no span used it yet.
+	fresh bool
+	// schema is the schema the session was last switched to, which
+	// outlives the conn going back to the pool.
+	schema string
+}
+
+func newConnInfo(d wrappedDriver, conn driver.Conn, name string) *connInfo {
+	cfg := d.cfg()
+	info := &connInfo{explain: newExplainer(d.parent, name, cfg), fresh: true}
+	if cfg.dsnAutoParse {
+		info.attrs = dsnAttrs(cfg.driverName, name)
+	}
+	if id, ok := cfg.serverConnID(conn); ok {
+		info.attrs = append(info.attrs, serverConnIDLbl.Int64(id))
+	}
+	if version, ok := cfg.serverVersion(conn); ok {
+		info.attrs = append(info.attrs, dbVersionLbl.String(version))
+	}
+	if cfg.connAge {
+		info.opened = time.Now()
+	}
+	return info
+}
+
+// ContextForConn associates ctx with conn, so that the transactions begun on
+// it without a traced context, e.g. through the legacy Begin, join the trace
+// of ctx. The association lasts until the connection goes back to the pool:
+//
+//	conn, err := db.Conn(ctx)
+//	if err != nil {
+//	    return err
+//	}
+//	defer conn.Close()
+//	if err := otsql.ContextForConn(ctx, conn); err != nil {
+//	    return err
+//	}
+//	legacyCodeCallingBegin(conn)
+//
+// The connection is marked as pinned as well, see MarkConnPinned.
+func ContextForConn(ctx context.Context, conn *sql.Conn) error {
+	return withConnInfo(conn, func(info *connInfo) {
+		info.mu.Lock()
+		defer info.mu.Unlock()
+		info.ctx = ctx
+		info.pinned = true
+	})
+}
+
+// MarkConnPinned records db.connection.pinned on the spans of conn until
+// it goes back to the pool, to tell apart the operations that deliberately
+// reuse one connection, e.g. for session temporary tables.
+func MarkConnPinned(conn *sql.Conn) error {
+	return withConnInfo(conn, func(info *connInfo) {
+		info.mu.Lock()
+		defer info.mu.Unlock()
+		info.pinned = true
+	})
+}
+
+func withConnInfo(conn *sql.Conn, f func(*connInfo)) error {
+	return conn.Raw(func(driverConn interface{}) error {
+		wc, ok := driverConn.(wrappedConn)
+		if !ok {
+			return ErrConnNotTraced
+		}
+		f(wc.info)
+		return nil
+	})
+}
+
+// release forgets the state associated with the conn by its last user.
+func (info *connInfo) release() {
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	info.ctx = nil
+	info.pinned = false
+	info.inTx = false
+}
+
+// setInTx records whether a transaction is active on the conn.
+func (info *connInfo) setInTx(inTx bool) {
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	info.inTx = inTx
+}
+
+// spanContext returns ctx, parented to the span of the context associated
+// with the conn if ctx doesn't carry a span of its own.
+func (info *connInfo) spanContext(ctx context.Context) context.Context {
+	if trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		return ctx
+	}
+
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	if info.ctx == nil {
+		return ctx
+	}
+	return trace.ContextWithSpan(ctx, trace.SpanFromContext(info.ctx))
+}
+
+// spanOption adds the attributes of the conn to a span, including whether
+// it runs within a transaction or is the first on a new conn.
+func (info *connInfo) spanOption() trace.StartOption {
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	if !info.pinned && !info.inTx && info.schema == "" && !info.fresh && info.opened.IsZero() {
+		return trace.WithAttributes(info.attrs...)
+	}
+	attrs := make([]label.KeyValue, 0, len(info.attrs)+5)
+	if info.fresh {
+		// The first operation on the conn paid for opening it.
+		attrs = append(attrs, connNewLbl.Bool(true))
+		info.fresh = false
+	}
+	attrs = append(attrs, info.attrs...)
+	if info.pinned {
+		attrs = append(attrs, connPinnedLbl.Bool(true))
+	}
+	if info.inTx {
+		attrs = append(attrs, inTransactionLbl.Bool(true))
+	}
+	if info.schema != "" {
+		attrs = append(attrs, schemaLbl.String(info.schema))
+	}
+	if !info.opened.IsZero() {
+		attrs = append(attrs, connAgeLbl.Int64(time.Since(info.opened).Milliseconds()))
+	}
+	return trace.WithAttributes(attrs...)
+}
+
+// dsnAttrs returns the attributes describing the server and database a DSN
+// connects to, or none when it can't be parsed.
+func dsnAttrs(driverName, name string) []label.KeyValue {
+	parsed, err := dsn.Parse(driverName, name)
+	if err != nil {
+		return nil
+	}
+
+	var attrs []label.KeyValue
+	if parsed.Host != "" {
+		attrs = append(attrs, semconv.NetPeerNameKey.String(parsed.Host))
+	}
+	if parsed.Port != 0 {
+		attrs = append(attrs, semconv.NetPeerPortKey.Int(parsed.Port))
+	}
+	if parsed.Database != "" {
+		attrs = append(attrs, semconv.DBNameKey.String(parsed.Database))
+	}
+	if parsed.User != "" {
+		attrs = append(attrs, semconv.DBUserKey.String(parsed.User))
+	}
+	return attrs
+}
+
+// connExpired reports whether the conn of info is closed because it reached
+// the max lifetime of conns, as far as its age tells.
+func (cfg *config) connExpired(info *connInfo) bool {
+	return cfg.connMaxLifetime > 0 && !info.opened.IsZero() && time.Since(info.opened) >= cfg.connMaxLifetime
+}
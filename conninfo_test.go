@@ -0,0 +1,60 @@
+package otsql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aybabtme/otsql"
+	"github.com/aybabtme/otsql/otsqltest"
+)
+
+func TestMarkConnPinned(t *testing.T) {
+	db, rec := openTestDB(t, &otsqltest.Driver{})
+	// A single conn, so that the pinned one is the one reused afterwards.
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := otsql.MarkConnPinned(conn); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, "CREATE TEMPORARY TABLE t (id int)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "DROP TABLE t"); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := rec.Named("sql-conn-exec")
+	if len(spans) != 2 {
+		t.Fatalf("got %d sql-conn-exec spans, want 2", len(spans))
+	}
+	if got := spans[0].Attr("db.connection.pinned"); got != true {
+		t.Errorf("db.connection.pinned = %v on the pinned conn, want true", got)
+	}
+	if got := spans[1].Attr("db.connection.pinned"); got != nil {
+		t.Errorf("db.connection.pinned = %v once back in the pool, want none", got)
+	}
+}
+
+func TestMarkConnPinnedUntracedConn(t *testing.T) {
+	db, err := sqlOpenRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := otsql.MarkConnPinned(conn); err != otsql.ErrConnNotTraced {
+		t.Errorf("MarkConnPinned on an untraced conn = %v, want %v", err, otsql.ErrConnNotTraced)
+	}
+}
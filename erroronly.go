@@ -0,0 +1,89 @@
+package otsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+)
+
+// deferredSpan stands in for the span of a call in error-only tracing: it
+// buffers what's recorded on it until the call ends, and only starts a
+// real span, backdated to the start of the call, when the call failed.
+type deferredSpan struct {
+	tracer trace.Tracer
+	ctx    context.Context
+	name   string
+	opts   []trace.StartOption
+
+	mu  sync.Mutex
+	ops []func(trace.Span)
+}
+
+func (s *deferredSpan) record(op func(trace.Span)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ops = append(s.ops, op)
+}
+
+// materialize starts the real span of a call that started at start and
+// returned err, replaying what was recorded, or returns false when the call
+// didn't fail.
+func (s *deferredSpan) materialize(start time.Time, err error) (trace.Span, bool) {
+	if err == nil || err == io.EOF || err == driver.ErrSkip || errors.Is(err, driver.ErrBadConn) {
+		return nil, false
+	}
+	_, span := s.tracer.Start(s.ctx, s.name, append(s.opts, trace.WithStartTime(start))...)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, op := range s.ops {
+		op(span)
+	}
+	return span, true
+}
+
+func (s *deferredSpan) Tracer() trace.Tracer { return s.tracer }
+
+// End does nothing: endSpan ends the real span, if any.
+func (s *deferredSpan) End(...trace.EndOption) {}
+
+func (s *deferredSpan) AddEvent(ctx context.Context, name string, attrs ...label.KeyValue) {
+	s.AddEventWithTimestamp(ctx, time.Now(), name, attrs...)
+}
+
+func (s *deferredSpan) AddEventWithTimestamp(ctx context.Context, timestamp time.Time, name string, attrs ...label.KeyValue) {
+	s.record(func(span trace.Span) { span.AddEventWithTimestamp(ctx, timestamp, name, attrs...) })
+}
+
+// IsRecording is true so that the attributes are there if the call fails.
+func (s *deferredSpan) IsRecording() bool { return true }
+
+func (s *deferredSpan) RecordError(ctx context.Context, err error, opts ...trace.ErrorOption) {
+	opts = append([]trace.ErrorOption{trace.WithErrorTime(time.Now())}, opts...)
+	s.record(func(span trace.Span) { span.RecordError(ctx, err, opts...) })
+}
+
+// SpanContext is invalid since the span may never exist.
+func (s *deferredSpan) SpanContext() trace.SpanContext { return trace.SpanContext{} }
+
+func (s *deferredSpan) SetStatus(code codes.Code, msg string) {
+	s.record(func(span trace.Span) { span.SetStatus(code, msg) })
+}
+
+func (s *deferredSpan) SetName(name string) {
+	s.record(func(span trace.Span) { span.SetName(name) })
+}
+
+func (s *deferredSpan) SetAttributes(attrs ...label.KeyValue) {
+	s.record(func(span trace.Span) { span.SetAttributes(attrs...) })
+}
+
+func (s *deferredSpan) SetAttribute(k string, v interface{}) {
+	s.record(func(span trace.Span) { span.SetAttribute(k, v) })
+}
@@ -0,0 +1,34 @@
+package otsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/api/trace"
+)
+
+func TestDeferredSpanMaterialize(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"success", nil, false},
+		{"end of rows", io.EOF, false},
+		{"skip", driver.ErrSkip, false},
+		{"bad conn", driver.ErrBadConn, false},
+		{"wrapped bad conn", errors.Wrap(driver.ErrBadConn, "resetting session"), false},
+		{"failure", errors.New("boom"), true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &deferredSpan{tracer: trace.NoopTracer{}, ctx: context.Background(), name: "sql-exec"}
+			if _, got := s.materialize(time.Now(), tt.err); got != tt.want {
+				t.Errorf("materialize(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package otsql
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Errors originating from the wrapper rather than the driver, to check for
+// with errors.Is.
+var (
+	// ErrDriverAlreadyRegistered is returned when registering a traced
+	// driver under a name that's taken, or tracing a driver that's already
+	// traced.
+	ErrDriverAlreadyRegistered = errors.New("otsql: driver is already registered")
+	// ErrConnNotTraced is returned when a connection expected to be traced
+	// isn't.
+	ErrConnNotTraced = errors.New("otsql: connection is not traced")
+	// ErrNamedParamsUnsupported is returned when a call with named
+	// parameters falls back to the methods of a driver that doesn't
+	// support them.
+	ErrNamedParamsUnsupported = errors.New("otsql: driver does not support the use of Named Parameters")
+)
@@ -0,0 +1,123 @@
+package otsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/label"
+)
+
+const (
+	defaultSlowQueryThreshold = time.Second
+	explainTimeout            = 10 * time.Second
+	maxPlanLength             = 4096
+)
+
+var planLbl = label.Key("db.plan")
+
+// explainer runs EXPLAIN for a sample of the slow SELECT queries of a conn,
+// on a separate connection.
+type explainer struct {
+	cfg        *config
+	parent     driver.Driver
+	dsn        string
+	sampleRate float64
+	threshold  time.Duration
+}
+
+func newExplainer(parent driver.Driver, dsn string, cfg *config) *explainer {
+	if cfg.autoExplainRate <= 0 {
+		return nil
+	}
+	return &explainer{
+		cfg:        cfg,
+		parent:     parent,
+		dsn:        dsn,
+		sampleRate: cfg.autoExplainRate,
+		threshold:  cfg.slowQueryThreshold,
+	}
+}
+
+func (e *explainer) shouldExplain(query string, elapsed time.Duration) bool {
+	if e == nil || elapsed < e.threshold {
+		return false
+	}
+	return e.cfg.tokenizer.sqlVerb(query) == "SELECT" && rand.Float64() < e.sampleRate
+}
+
+// explainAfter records the plan of query on a sql-explain span, child of
+// the span of the query in ctx, which already ended. The EXPLAIN runs in the
+// background so the caller doesn't wait for it, unless the instrumentation
+// was shut down.
+func (e *explainer) explainAfter(ctx context.Context, query string, args []driver.NamedValue) {
+	// The EXPLAIN outlives the query, and so its context.
+	parent := trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx))
+	e.cfg.lifecycle.goBackground(func() {
+		ctx, cancel := context.WithTimeout(parent, explainTimeout)
+		defer cancel()
+
+		ctx, span := e.cfg.startSpan(ctx, "sql-explain")
+		if !e.cfg.statementOnErrorOnly {
+			// Recorded like on the query span, e.g. obfuscated under
+			// StrictPrivacy.
+			span.SetAttributes(e.cfg.appendStatement(nil, span, query, nil)...)
+		}
+		plan, err := e.explain(ctx, query, args)
+		if err != nil {
+			e.cfg.recordError(ctx, span, err)
+		} else {
+			span.SetAttributes(planLbl.String(truncate(plan, maxPlanLength)))
+		}
+		e.cfg.endSpan(span, err)
+	})
+}
+
+func (e *explainer) explain(ctx context.Context, query string, args []driver.NamedValue) (string, error) {
+	conn, err := e.parent.Open(e.dsn)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	stmt, err := conn.Prepare("EXPLAIN " + query)
+	if err != nil {
+		return "", err
+	}
+	defer stmt.Close()
+
+	var rows driver.Rows
+	if stmtQueryContext, ok := stmt.(driver.StmtQueryContext); ok {
+		rows, err = stmtQueryContext.QueryContext(ctx, args)
+	} else {
+		var dargs []driver.Value
+		if dargs, err = namedValueToValue(args); err == nil {
+			rows, err = stmt.Query(dargs)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var plan strings.Builder
+	dest := make([]driver.Value, len(rows.Columns()))
+	for rows.Next(dest) == nil && plan.Len() < maxPlanLength {
+		for i, v := range dest {
+			if i > 0 {
+				plan.WriteByte('\t')
+			}
+			if b, ok := v.([]byte); ok {
+				plan.Write(b)
+			} else {
+				fmt.Fprint(&plan, v)
+			}
+		}
+		plan.WriteByte('\n')
+	}
+	return plan.String(), nil
+}
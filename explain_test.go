@@ -0,0 +1,80 @@
+package otsql_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/aybabtme/otsql"
+	"github.com/aybabtme/otsql/otsqltest"
+)
+
+// runAutoExplain runs a query on a driver instrumented as nameSuffix with
+// opts, explaining every query, and returns the recorder once the explain
+// is done.
+func runAutoExplain(t *testing.T, nameSuffix, query string, opts ...otsql.Option) *otsqltest.Recorder {
+	t.Helper()
+	rec := otsqltest.NewRecorder()
+	drv := &otsqltest.Driver{Columns: []string{"plan"}, Rows: [][]driver.Value{{"Seq Scan on t"}}}
+	opts = append([]otsql.Option{otsql.WithAutoExplain(1), otsql.WithSlowQueryThreshold(0)}, opts...)
+	inst, err := otsql.Instrument(nameSuffix, drv, rec.Tracer(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(inst.DriverName(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	if err := inst.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if spans := rec.Named("sql-explain"); len(spans) != 1 {
+		t.Fatalf("got %d sql-explain spans, want 1", len(spans))
+	}
+	return rec
+}
+
+func TestAutoExplainDoesNotHoldQuerySpan(t *testing.T) {
+	rec := runAutoExplain(t, "test-explain", "SELECT plan FROM t")
+
+	var query, explain *otsqltest.Span
+	for i, span := range rec.Spans() {
+		span := span
+		switch span.Name {
+		case "sql-conn-query":
+			if explain != nil {
+				t.Errorf("query span ended after the explain span, #%d", i)
+			}
+			query = &span
+		case "sql-explain":
+			explain = &span
+		}
+	}
+	if query == nil || explain == nil {
+		t.Fatalf("got query span %v and explain span %v, want both", query, explain)
+	}
+	if explain.ParentSpanID != query.SpanContext.SpanID {
+		t.Errorf("explain span parent = %v, want the query span %v", explain.ParentSpanID, query.SpanContext.SpanID)
+	}
+	if got, want := explain.Attr("db.plan"), "Seq Scan on t\n"; got != want {
+		t.Errorf("db.plan = %q, want %q", got, want)
+	}
+}
+
+func TestAutoExplainStatementOnErrorOnly(t *testing.T) {
+	rec := runAutoExplain(t, "test-explain-error-only", "SELECT plan FROM t WHERE id = 42",
+		otsql.WithStatementOnErrorOnly(),
+	)
+	explain := rec.Named("sql-explain")[0]
+	if got := explain.Attr("query"); got != nil {
+		t.Errorf("query = %v, want none", got)
+	}
+}
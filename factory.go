@@ -0,0 +1,37 @@
+package otsql
+
+import (
+	"database/sql/driver"
+
+	"go.opentelemetry.io/otel/api/trace"
+)
+
+// WrapperFactory wraps several drivers with the same tracer and options,
+// e.g. for an app connecting to many databases.
+type WrapperFactory struct {
+	tracer trace.Tracer
+	opts   []Option
+}
+
+// NewWrapperFactory returns a factory wrapping drivers with tracer and opts.
+func NewWrapperFactory(tracer trace.Tracer, opts ...Option) *WrapperFactory {
+	return &WrapperFactory{tracer: tracer, opts: opts}
+}
+
+// Wrap is WrapDriver with the shared tracer and options, followed by opts
+// for options specific to driver.
+func (f *WrapperFactory) Wrap(nameSuffix string, driver driver.Driver, opts ...Option) (string, error) {
+	inst, err := f.Instrument(nameSuffix, driver, opts...)
+	if err != nil {
+		return "", err
+	}
+	return inst.DriverName(), nil
+}
+
+// Instrument is Instrument with the shared tracer and options, followed by
+// opts for options specific to driver.
+func (f *WrapperFactory) Instrument(nameSuffix string, driver driver.Driver, opts ...Option) (*Instrumentation, error) {
+	all := make([]Option, 0, len(f.opts)+len(opts))
+	all = append(all, f.opts...)
+	return Instrument(nameSuffix, driver, f.tracer, append(all, opts...)...)
+}
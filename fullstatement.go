@@ -0,0 +1,60 @@
+package otsql
+
+import (
+	"context"
+
+	"github.com/kr/pretty"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/label"
+)
+
+var (
+	// fullStatementLbl marks the spans that recorded their statement in
+	// full through WithFullStatement.
+	fullStatementLbl = label.Key("db.statement.full")
+	// fullStatementArgsLbl tells whether the arguments were recorded in
+	// full too, on the audit event of a span.
+	fullStatementArgsLbl = label.Key("db.statement.full.args")
+)
+
+type fullStatementKey struct{}
+
+// WithFullStatement returns a context whose DB calls record their statement
+// as is, and their arguments too when args is true, overriding the options
+// that omit, redact or truncate them. It's an escape hatch for debugging a
+// live issue: the spans recorded this way carry db.statement.full=true and
+// a sql-full-statement event, and are reported to the hook given to
+// WithFullStatementAudit, so that its use can be audited.
+func WithFullStatement(ctx context.Context, args bool) context.Context {
+	return context.WithValue(ctx, fullStatementKey{}, args)
+}
+
+// fullStatement reports whether the calls made with ctx record their
+// statement in full, and whether they record their arguments too.
+func fullStatement(ctx context.Context) (full, args bool) {
+	args, full = ctx.Value(fullStatementKey{}).(bool)
+	return full, args
+}
+
+// appendFullStatement appends the attributes recording query, and args
+// when withArgs is true, in full to attrs.
+func (cfg *config) appendFullStatement(attrs []label.KeyValue, query string, args interface{}, withArgs bool) []label.KeyValue {
+	attrs = append(attrs,
+		queryLbl.String(query),
+		sizeClassLbl.String(sizeClass(len(query))),
+		fullStatementLbl.Bool(true),
+	)
+	if withArgs && args != nil {
+		attrs = append(attrs, cfg.argsKey.String(pretty.Sprint(args)))
+	}
+	return attrs
+}
+
+// auditFullStatement reports that span recorded query in full, and its
+// arguments too when withArgs is true.
+func (cfg *config) auditFullStatement(ctx context.Context, span trace.Span, query string, withArgs bool) {
+	span.AddEvent(ctx, "sql-full-statement", fullStatementArgsLbl.Bool(withArgs))
+	if cfg.fullStatementAudit != nil {
+		cfg.fullStatementAudit(ctx, query, withArgs)
+	}
+}
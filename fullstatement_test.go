@@ -0,0 +1,62 @@
+package otsql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aybabtme/otsql"
+	"github.com/aybabtme/otsql/otsqltest"
+)
+
+func TestFullStatementReplacesRecordedStatement(t *testing.T) {
+	const query = "UPDATE users SET ssn = '123-45-6789' WHERE id = ?"
+
+	type audit struct {
+		query string
+		args  bool
+	}
+	var audits []audit
+	db, rec := openTestDB(t, &otsqltest.Driver{},
+		otsql.WithDualStatement(),
+		otsql.WithFullStatementAudit(func(ctx context.Context, query string, args bool) {
+			audits = append(audits, audit{query, args})
+		}),
+	)
+	ctx := otsql.WithFullStatement(context.Background(), false)
+	if _, err := db.ExecContext(ctx, query, 42); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(context.Background(), query, 42); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := rec.Named("sql-conn-exec")
+	if len(spans) != 2 {
+		t.Fatalf("got %d sql-conn-exec spans, want 2", len(spans))
+	}
+	full := spans[0]
+	if got := full.Attr("query"); got != query {
+		t.Errorf("query = %v, want %q", got, query)
+	}
+	if got := full.Attr("db.statement.raw"); got != nil {
+		t.Errorf("db.statement.raw = %v, want none next to the full statement", got)
+	}
+	if got := full.Attr("db.statement.full"); got != true {
+		t.Errorf("db.statement.full = %v, want true", got)
+	}
+	if !full.HasEvent("sql-full-statement") {
+		t.Error("the span has no sql-full-statement event")
+	}
+
+	// Without the override, the statement is recorded as configured.
+	if got := spans[1].Attr("db.statement.raw"); got != query {
+		t.Errorf("db.statement.raw = %v, want %q", got, query)
+	}
+	if spans[1].HasEvent("sql-full-statement") {
+		t.Error("the span without the override has a sql-full-statement event")
+	}
+
+	if len(audits) != 1 || audits[0] != (audit{query, false}) {
+		t.Errorf("audited %+v, want the full statement once", audits)
+	}
+}
@@ -3,7 +3,9 @@ module github.com/aybabtme/otsql
 go 1.15
 
 require (
+	github.com/jackc/pgx/v4 v4.10.1
 	github.com/kr/pretty v0.2.1
 	github.com/pkg/errors v0.9.1
 	go.opentelemetry.io/otel v0.11.0
+	go.opentelemetry.io/otel/sdk v0.11.0
 )
@@ -0,0 +1,26 @@
+package otsql
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+
+	"go.opentelemetry.io/otel/label"
+)
+
+var goroutineIDLbl = label.Key("db.goroutine_id")
+
+// goroutineID returns the id of the calling goroutine, parsed from the
+// header of its stack trace, "goroutine 123 [running]:". Go doesn't expose
+// the id otherwise, and this costs a stack trace per call: it's a debugging
+// hack, not something to leave on.
+func goroutineID() (int64, bool) {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	return id, err == nil
+}
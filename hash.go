@@ -0,0 +1,44 @@
+package otsql
+
+import (
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/label"
+)
+
+var queryHashLbl = label.Key("db.statement.hash")
+
+// hashQuery returns a stable fingerprint of query, insensitive to
+// differences in literals and whitespace, so that the calls of a statement
+// group together whatever values they're made with.
+func hashQuery(tz *SQLTokenizer, query string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strings.Join(strings.Fields(obfuscate(tz, query)), " ")))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// stmtHash lazily computes and caches the hash of the query of a prepared
+// statement, so repeated execs don't hash it again.
+type stmtHash struct {
+	once  sync.Once
+	tz    *SQLTokenizer
+	query string
+	sum   string
+}
+
+func (cfg *config) newStmtHash(query string) *stmtHash {
+	if !cfg.queryHash {
+		return nil
+	}
+	return &stmtHash{tz: cfg.tokenizer, query: query}
+}
+
+func (h *stmtHash) get() string {
+	h.once.Do(func() {
+		h.sum = hashQuery(h.tz, h.query)
+	})
+	return h.sum
+}
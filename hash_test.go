@@ -0,0 +1,26 @@
+package otsql
+
+import "testing"
+
+func TestHashQuery(t *testing.T) {
+	same := [][2]string{
+		{"SELECT * FROM t WHERE id = 1", "SELECT * FROM t WHERE id = 2"},
+		{"SELECT * FROM t WHERE name = 'a'", "SELECT * FROM t WHERE name = 'b'"},
+		{"SELECT *  FROM t\n\tWHERE id = 1", "SELECT * FROM t WHERE id = 1"},
+	}
+	for _, q := range same {
+		if a, b := hashQuery(&GenericTokenizer, q[0]), hashQuery(&GenericTokenizer, q[1]); a != b {
+			t.Errorf("hash of %q = %s, of %q = %s, want them equal", q[0], a, q[1], b)
+		}
+	}
+
+	different := [][2]string{
+		{"SELECT * FROM t WHERE id = 1", "SELECT * FROM u WHERE id = 1"},
+		{"SELECT * FROM t WHERE id = 1", "DELETE FROM t WHERE id = 1"},
+	}
+	for _, q := range different {
+		if a, b := hashQuery(&GenericTokenizer, q[0]), hashQuery(&GenericTokenizer, q[1]); a == b {
+			t.Errorf("hash of %q and of %q = %s, want them different", q[0], q[1], a)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package otsql_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aybabtme/otsql"
+	"github.com/aybabtme/otsql/otsqltest"
+)
+
+var testDrivers int64
+
+func init() {
+	sql.Register("test-untraced", &otsqltest.Driver{})
+}
+
+// sqlOpenRaw opens a database on the untraced test driver.
+func sqlOpenRaw() (*sql.DB, error) {
+	return sql.Open("test-untraced", "test")
+}
+
+// openTestDB opens a database on a traced version of drv, registered under
+// a name of its own, and returns it with the recorder of its spans.
+func openTestDB(t *testing.T, drv driver.Driver, opts ...otsql.Option) (*sql.DB, *otsqltest.Recorder) {
+	t.Helper()
+	return openTestDSN(t, drv, "test", opts...)
+}
+
+// openTestDSN is openTestDB connecting to dsn.
+func openTestDSN(t *testing.T, drv driver.Driver, dsn string, opts ...otsql.Option) (*sql.DB, *otsqltest.Recorder) {
+	t.Helper()
+	rec := otsqltest.NewRecorder()
+	name, err := otsql.WrapDriver("test-"+strconv.FormatInt(atomic.AddInt64(&testDrivers, 1), 10), drv, rec.Tracer(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(name, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, rec
+}
@@ -0,0 +1,148 @@
+package otsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/api/metric"
+	"go.opentelemetry.io/otel/api/trace"
+)
+
+const (
+	// InstrumentationName is the name of the tracers derived by this
+	// library.
+	InstrumentationName = "github.com/aybabtme/otsql"
+	// InstrumentationVersion is the version of this library.
+	InstrumentationVersion = "0.1.0"
+)
+
+// Instrumentation is the handle of a traced driver, owning the background
+// work of its instrumentation.
+type Instrumentation struct {
+	name string
+	live *liveConfig
+}
+
+// registerMu makes checking that a driver name is free and registering it
+// atomic, for the drivers registered by this package.
+var registerMu sync.Mutex
+
+// Instrument registers a traced version of driver, like WrapDriver, and
+// returns its handle. It returns ErrDriverAlreadyRegistered when a driver
+// is already registered under the traced name.
+func Instrument(nameSuffix string, driver driver.Driver, tracer trace.Tracer, opts ...Option) (*Instrumentation, error) {
+	name := "traced-" + nameSuffix
+	registerMu.Lock()
+	defer registerMu.Unlock()
+	// sql.Register panics on a name that's taken.
+	drivers := sql.Drivers()
+	if i := sort.SearchStrings(drivers, name); i < len(drivers) && drivers[i] == name {
+		return nil, errors.Wrapf(ErrDriverAlreadyRegistered, "registering %q", name)
+	}
+
+	cfg := newConfig(tracer, opts)
+	cfg.driverName = nameSuffix
+	live := newLiveConfig(tracer, cfg)
+	sql.Register(name, wrappedDriver{parent: driver, live: live})
+	return &Instrumentation{name: name, live: live}, nil
+}
+
+// DriverName returns the name the traced driver is registered under, to
+// pass to sql.Open.
+func (i *Instrumentation) DriverName() string {
+	return i.name
+}
+
+// Shutdown stops starting background work, such as auto-explains, stops
+// the stats reporters it owns, waits for the ongoing work to finish or for
+// ctx to be done, and then flushes the metrics. It's safe to call more than
+// once.
+func (i *Instrumentation) Shutdown(ctx context.Context) error {
+	if err := i.live.load().lifecycle.shutdown(ctx); err != nil {
+		return err
+	}
+	return i.Flush(ctx)
+}
+
+// Flush exports the metrics recorded so far with the flush func given to
+// WithMetricsFlush, e.g. before a short-lived job or serverless function
+// exits. It does nothing without one.
+func (i *Instrumentation) Flush(ctx context.Context) error {
+	cfg := i.live.load()
+	if cfg.metricsFlush == nil {
+		return nil
+	}
+	return errors.Wrap(cfg.metricsFlush(ctx), "flushing metrics")
+}
+
+// NewStatsReporter starts a StatsReporter, like the package function, owned
+// by the instrumentation: Shutdown stops it before flushing the metrics.
+func (i *Instrumentation) NewStatsReporter(db *sql.DB, meter metric.Meter, opts ...StatsOption) (*StatsReporter, error) {
+	return newStatsReporter(db, meter, i.live.load().lifecycle, opts)
+}
+
+// UpdateConfig replaces the options of the traced driver by opts, e.g. to
+// toggle the capture of arguments from an admin endpoint, without reopening
+// its connections. Options given before aren't kept. Calls in progress may
+// finish under the previous options, and the options read when a connection
+// opens, such as auto-explain or DSN parsing, only apply to connections
+// opened afterwards.
+func (i *Instrumentation) UpdateConfig(opts ...Option) {
+	i.live.update(opts)
+}
+
+// lifecycle tracks the background goroutines of an instrumentation.
+type lifecycle struct {
+	wg       sync.WaitGroup
+	once     sync.Once
+	mu       sync.RWMutex
+	shutDown bool
+	// stopping is closed on shutdown, for the goroutines running until
+	// then.
+	stopping chan struct{}
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{stopping: make(chan struct{})}
+}
+
+// goBackground runs f in a goroutine unless the instrumentation was shut
+// down, and reports whether it did.
+func (l *lifecycle) goBackground(f func()) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.shutDown {
+		return false
+	}
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		f()
+	}()
+	return true
+}
+
+func (l *lifecycle) shutdown(ctx context.Context) error {
+	l.once.Do(func() {
+		l.mu.Lock()
+		l.shutDown = true
+		l.mu.Unlock()
+		close(l.stopping)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,128 @@
+package otsql_test
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aybabtme/otsql"
+	"github.com/aybabtme/otsql/otsqltest"
+	"github.com/pkg/errors"
+	export "go.opentelemetry.io/otel/sdk/export/metric"
+	"go.opentelemetry.io/otel/sdk/export/metric/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/controller/push"
+	"go.opentelemetry.io/otel/sdk/metric/processor/basic"
+	"go.opentelemetry.io/otel/sdk/metric/selector/simple"
+)
+
+// sumExporter keeps the sums of the last export it's given, by instrument.
+type sumExporter struct {
+	export.ExportKindSelector
+
+	mu   sync.Mutex
+	sums map[string]float64
+}
+
+func (e *sumExporter) Export(_ context.Context, ckpt export.CheckpointSet) error {
+	sums := make(map[string]float64)
+	err := ckpt.ForEach(e, func(rec export.Record) error {
+		if s, ok := rec.Aggregation().(aggregation.Sum); ok {
+			sum, err := s.Sum()
+			if err != nil {
+				return err
+			}
+			sums[rec.Descriptor().Name()] += sum.CoerceToFloat64(rec.Descriptor().NumberKind())
+		}
+		return nil
+	})
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sums = sums
+	return err
+}
+
+func (e *sumExporter) exported() map[string]float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.sums
+}
+
+func TestShutdownFlushesMetrics(t *testing.T) {
+	exporter := &sumExporter{ExportKindSelector: export.CumulativeExporter}
+	ctrl := push.New(
+		basic.New(simple.NewWithInexpensiveDistribution(), exporter),
+		exporter,
+		// Never exports by itself, only when stopped.
+		push.WithPeriod(time.Hour),
+	)
+	ctrl.Start()
+
+	inst, err := otsql.Instrument("test-flush", &otsqltest.Driver{}, otsqltest.NewRecorder().Tracer(),
+		otsql.WithMeter(ctrl.Provider().Meter("test")),
+		otsql.WithMetricsFlush(func(ctx context.Context) error {
+			ctrl.Stop()
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(inst.DriverName(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	stmt, err := db.Prepare("SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	if sums := exporter.exported(); sums != nil {
+		t.Fatalf("exported %v before Shutdown, want nothing", sums)
+	}
+	if err := inst.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	const name = "db.client.prepared_statements"
+	if got, ok := exporter.exported()[name]; !ok || got != 1 {
+		t.Errorf("exported %s = %v (%t), want 1", name, got, ok)
+	}
+}
+
+func TestShutdownReturnsFlushError(t *testing.T) {
+	errFlush := errors.New("exporter unavailable")
+	inst, err := otsql.Instrument("test-flush-error", &otsqltest.Driver{}, otsqltest.NewRecorder().Tracer(),
+		otsql.WithMetricsFlush(func(ctx context.Context) error {
+			return errFlush
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.Shutdown(context.Background()); errors.Cause(err) != errFlush {
+		t.Errorf("got error %v, want %v", err, errFlush)
+	}
+}
+
+func TestFlushWithoutFunc(t *testing.T) {
+	inst, err := otsql.Instrument("test-flush-none", &otsqltest.Driver{}, otsqltest.NewRecorder().Tracer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.Flush(context.Background()); err != nil {
+		t.Errorf("got error %v, want none", err)
+	}
+}
+
+func TestInstrumentDuplicateName(t *testing.T) {
+	if _, err := otsql.Instrument("test-duplicate", &otsqltest.Driver{}, otsqltest.NewRecorder().Tracer()); err != nil {
+		t.Fatal(err)
+	}
+	_, err := otsql.WrapDriver("test-duplicate", &otsqltest.Driver{}, otsqltest.NewRecorder().Tracer())
+	if !errors.Is(err, otsql.ErrDriverAlreadyRegistered) {
+		t.Errorf("got error %v, want %v", err, otsql.ErrDriverAlreadyRegistered)
+	}
+}
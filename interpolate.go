@@ -0,0 +1,98 @@
+package otsql
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// interpolate renders query with its placeholders replaced by the quoted
+// values of args, for debugging, and returns the placeholder style it
+// detected. Only placeholders of that style are replaced, and those
+// without a matching argument are left as is.
+func interpolate(tz *SQLTokenizer, query string, args interface{}) (string, placeholderStyle) {
+	var named []driver.NamedValue
+	switch a := args.(type) {
+	case []driver.NamedValue:
+		named = a
+	case []driver.Value:
+		named = make([]driver.NamedValue, len(a))
+		for i, v := range a {
+			named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+		}
+	}
+	tokens := tz.tokenize(query)
+	style := detectPlaceholderStyle(tokens)
+	if len(named) == 0 || style == styleUnknown {
+		return query, style
+	}
+
+	var b strings.Builder
+	next := 0
+	for _, tok := range tokens {
+		var (
+			arg driver.NamedValue
+			ok  bool
+		)
+		switch ts := tokenStyle(tok); {
+		case ts != style:
+		case ts == styleQuestion:
+			if next < len(named) {
+				arg, ok = named[next], true
+				next++
+			}
+		case ts == styleDollar:
+			if n, err := strconv.Atoi(tok.text[1:]); err == nil && n >= 1 && n <= len(named) {
+				arg, ok = named[n-1], true
+			}
+		default:
+			for _, nv := range named {
+				if nv.Name == tok.text[1:] {
+					arg, ok = nv, true
+					break
+				}
+			}
+		}
+
+		if ok {
+			b.WriteString(quoteValue(arg.Value))
+		} else {
+			b.WriteString(tok.text)
+		}
+	}
+	return b.String(), style
+}
+
+// quoteValue renders v as a SQL literal. Strings are quoted with their
+// quotes and backslashes escaped, so that a value can't terminate the
+// literal early whatever the dialect.
+func quoteValue(v driver.Value) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []byte:
+		return "X'" + hex.EncodeToString(v) + "'"
+	case string:
+		return quoteString(v)
+	case time.Time:
+		return quoteString(v.Format(time.RFC3339Nano))
+	default:
+		return quoteString(fmt.Sprint(v))
+	}
+}
+
+func quoteString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `''`).Replace(s) + "'"
+}
@@ -0,0 +1,28 @@
+package otsql
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/api/trace"
+)
+
+type spanLinksKey struct{}
+
+// WithSpanLinks returns a context linking the spans started under it to the
+// spans of linked, e.g. the context of a message consumed from a queue that
+// caused the DB calls. Links add up with those of ctx. Contexts without a
+// valid span are ignored.
+func WithSpanLinks(ctx context.Context, linked ...context.Context) context.Context {
+	links := spanLinksFromContext(ctx)
+	for _, l := range linked {
+		if sc := trace.SpanFromContext(l).SpanContext(); sc.IsValid() {
+			links = append(links[:len(links):len(links)], sc)
+		}
+	}
+	return context.WithValue(ctx, spanLinksKey{}, links)
+}
+
+func spanLinksFromContext(ctx context.Context) []trace.SpanContext {
+	links, _ := ctx.Value(spanLinksKey{}).([]trace.SpanContext)
+	return links
+}
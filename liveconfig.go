@@ -0,0 +1,46 @@
+package otsql
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/api/trace"
+)
+
+// liveConfig holds the active config of a traced driver, which its handle
+// can replace at runtime. Calls load it without locking.
+type liveConfig struct {
+	v atomic.Value
+
+	mu     sync.Mutex
+	tracer trace.Tracer
+}
+
+func newLiveConfig(tracer trace.Tracer, cfg *config) *liveConfig {
+	l := &liveConfig{tracer: tracer}
+	l.v.Store(cfg)
+	return l
+}
+
+func (l *liveConfig) load() *config {
+	return l.v.Load().(*config)
+}
+
+// update replaces the active config by one made of opts. The background
+// work and the identity of the driver carry over.
+func (l *liveConfig) update(opts []Option) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prev := l.load()
+	cfg := newConfig(l.tracer, opts)
+	cfg.lifecycle = prev.lifecycle
+	cfg.driverName = prev.driverName
+	l.v.Store(cfg)
+}
+
+func (d wrappedDriver) cfg() *config { return d.live.load() }
+func (c wrappedConn) cfg() *config   { return c.live.load() }
+func (t wrappedTx) cfg() *config     { return t.live.load() }
+func (s wrappedStmt) cfg() *config   { return s.live.load() }
+func (r wrappedResult) cfg() *config { return r.live.load() }
+func (r wrappedRows) cfg() *config   { return r.live.load() }
@@ -0,0 +1,121 @@
+package otsql
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/metric"
+	"go.opentelemetry.io/otel/api/unit"
+	"go.opentelemetry.io/otel/label"
+)
+
+// instruments holds the metric instruments of a wrapped driver. They are
+// no-ops unless a meter is configured.
+type instruments struct {
+	resets   metric.Int64Counter
+	inFlight metric.Int64UpDownCounter
+	prepared metric.Int64UpDownCounter
+
+	prepareDuration metric.Float64ValueRecorder
+
+	pingDuration metric.Float64ValueRecorder
+	pingFailures metric.Int64Counter
+}
+
+func newInstruments(meter metric.Meter) *instruments {
+	var (
+		inst instruments
+		err  error
+	)
+	if inst.resets, err = meter.NewInt64Counter("db.client.connection.reset",
+		metric.WithDescription("Connections reset before being reused from the pool")); err != nil {
+		global.Handle(errors.Wrap(err, "creating connection reset counter"))
+	}
+	if inst.inFlight, err = meter.NewInt64UpDownCounter("db.client.queries.in_flight",
+		metric.WithDescription("Exec and query calls in progress")); err != nil {
+		global.Handle(errors.Wrap(err, "creating in-flight queries counter"))
+	}
+	if inst.prepared, err = meter.NewInt64UpDownCounter("db.client.prepared_statements",
+		metric.WithDescription("Statements prepared and not closed yet")); err != nil {
+		global.Handle(errors.Wrap(err, "creating prepared statements counter"))
+	}
+	if inst.prepareDuration, err = meter.NewFloat64ValueRecorder("db.client.prepare.duration",
+		metric.WithDescription("Duration of statement preparations"),
+		metric.WithUnit(unit.Milliseconds)); err != nil {
+		global.Handle(errors.Wrap(err, "creating prepare duration recorder"))
+	}
+	if inst.pingDuration, err = meter.NewFloat64ValueRecorder("db.client.ping.duration",
+		metric.WithDescription("Duration of pings to the server"),
+		metric.WithUnit(unit.Milliseconds)); err != nil {
+		global.Handle(errors.Wrap(err, "creating ping duration recorder"))
+	}
+	if inst.pingFailures, err = meter.NewInt64Counter("db.client.ping.failures",
+		metric.WithDescription("Pings to the server that failed")); err != nil {
+		global.Handle(errors.Wrap(err, "creating ping failures counter"))
+	}
+	return &inst
+}
+
+// metricLabels returns the labels to record the metrics of a call with.
+func (cfg *config) metricLabels(ctx context.Context) []label.KeyValue {
+	if cfg.metricLabelsFromContext == nil {
+		return nil
+	}
+	return cfg.metricLabelsFromContext(ctx)
+}
+
+// trackInFlight counts a call as in flight until the returned func is
+// called.
+func (cfg *config) trackInFlight(ctx context.Context) func() {
+	labels := cfg.metricLabels(ctx)
+	cfg.metrics.inFlight.Add(ctx, 1, labels...)
+	return func() {
+		cfg.metrics.inFlight.Add(ctx, -1, labels...)
+	}
+}
+
+// trackPrepared counts a statement prepared on the conn of info. The count
+// carries no labels from the context, since the statement may be closed
+// under another context, or not at all before its conn is.
+func (cfg *config) trackPrepared(info *connInfo) {
+	atomic.AddInt64(&info.prepared, 1)
+	cfg.metrics.prepared.Add(context.Background(), 1)
+}
+
+// untrackPrepared uncounts up to n statements prepared on the conn of info,
+// which are being closed, and returns how many it uncounted. A conn being
+// closed uncounts the statements left open on it, so that their own closing
+// afterwards doesn't uncount them twice.
+func (cfg *config) untrackPrepared(info *connInfo, n int64) int64 {
+	for {
+		open := atomic.LoadInt64(&info.prepared)
+		if n > open {
+			n = open
+		}
+		if n <= 0 {
+			return 0
+		}
+		if atomic.CompareAndSwapInt64(&info.prepared, open, open-n) {
+			cfg.metrics.prepared.Add(context.Background(), -n)
+			return n
+		}
+	}
+}
+
+// recordPrepare records the duration of a statement preparation.
+func (cfg *config) recordPrepare(ctx context.Context, d time.Duration) {
+	cfg.metrics.prepareDuration.Record(ctx, float64(d)/float64(time.Millisecond), cfg.metricLabels(ctx)...)
+}
+
+// recordPing records the duration of a ping and whether it failed, whether
+// or not the ping gets a span.
+func (cfg *config) recordPing(ctx context.Context, d time.Duration, err error) {
+	labels := cfg.metricLabels(ctx)
+	cfg.metrics.pingDuration.Record(ctx, float64(d)/float64(time.Millisecond), labels...)
+	if err != nil {
+		cfg.metrics.pingFailures.Add(ctx, 1, labels...)
+	}
+}
@@ -0,0 +1,102 @@
+package otsql_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/aybabtme/otsql"
+	"github.com/aybabtme/otsql/otsqltest"
+	export "go.opentelemetry.io/otel/sdk/export/metric"
+	"go.opentelemetry.io/otel/sdk/export/metric/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/controller/pull"
+	"go.opentelemetry.io/otel/sdk/metric/processor/basic"
+	"go.opentelemetry.io/otel/sdk/metric/selector/simple"
+)
+
+// duringDriver opens conns whose stmts only implement driver.Stmt, and
+// calls during while they run.
+type duringDriver struct {
+	otsqltest.Driver
+	during func()
+}
+
+func (d *duringDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	return duringConn{conn, d}, err
+}
+
+type duringConn struct {
+	driver.Conn
+	d *duringDriver
+}
+
+func (c duringConn) Prepare(query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.Prepare(query)
+	return duringStmt{stmt, c.d}, err
+}
+
+type duringStmt struct {
+	driver.Stmt
+	d *duringDriver
+}
+
+func (s duringStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.during()
+	return s.Stmt.Exec(args)
+}
+
+func (s duringStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.during()
+	return s.Stmt.Query(args)
+}
+
+func TestInFlightCountsFallbackCallsOnce(t *testing.T) {
+	ctrl := pull.New(
+		basic.New(simple.NewWithInexpensiveDistribution(), export.CumulativeExporter, basic.WithMemory(true)),
+		pull.WithCachePeriod(0),
+	)
+	inFlight := func() float64 {
+		t.Helper()
+		if err := ctrl.Collect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		var n float64
+		err := ctrl.ForEach(export.CumulativeExporter, func(rec export.Record) error {
+			if rec.Descriptor().Name() != "db.client.queries.in_flight" {
+				return nil
+			}
+			sum, err := rec.Aggregation().(aggregation.Sum).Sum()
+			n += sum.CoerceToFloat64(rec.Descriptor().NumberKind())
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	var during []float64
+	drv := &duringDriver{during: func() { during = append(during, inFlight()) }}
+	db, _ := openTestDB(t, drv, otsql.WithMeter(ctrl.Provider().Meter("test")))
+	stmt, err := db.PrepareContext(context.Background(), "SELECT id FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if _, err := stmt.ExecContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := stmt.QueryContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	if len(during) != 2 || during[0] != 1 || during[1] != 1 {
+		t.Errorf("in flight during the exec and the query = %v, want [1 1]", during)
+	}
+	if n := inFlight(); n != 0 {
+		t.Errorf("in flight after the calls = %v, want 0", n)
+	}
+}
@@ -0,0 +1,27 @@
+package otsql
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/otel/label"
+)
+
+// rawStatementLbl is the statement as recorded without obfuscation, when
+// both forms are recorded.
+var rawStatementLbl = label.Key("db.statement.raw")
+
+// obfuscate returns query with its string and number literals replaced by
+// `?`, so that statements differing only by their literals read the same.
+func obfuscate(tz *SQLTokenizer, query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	for _, tok := range tz.tokenize(query) {
+		switch tok.kind {
+		case tokString, tokNumber:
+			b.WriteByte('?')
+		default:
+			b.WriteString(tok.text)
+		}
+	}
+	return b.String()
+}
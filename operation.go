@@ -0,0 +1,51 @@
+package otsql
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/label"
+)
+
+var operationNameLbl = label.Key("db.operation.name")
+
+type operationKey struct{}
+
+// operation is a logical unit of work grouping several DB calls.
+type operation struct {
+	name string
+
+	mu        sync.Mutex
+	firstExec trace.SpanContext
+}
+
+// WithOperationName returns a context naming the logical operation that the
+// DB calls made with it belong to, e.g. "import-batch". The name is recorded
+// on every span started under the context.
+func WithOperationName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, operationKey{}, &operation{name: name})
+}
+
+func operationFromContext(ctx context.Context) *operation {
+	op, _ := ctx.Value(operationKey{}).(*operation)
+	return op
+}
+
+// firstExecSpan returns the span context of the first exec of the
+// operation, if any was recorded yet.
+func (op *operation) firstExecSpan() (trace.SpanContext, bool) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	return op.firstExec, op.firstExec.IsValid()
+}
+
+// setFirstExecSpan records sc as the first exec of the operation, unless a
+// concurrent exec got there first.
+func (op *operation) setFirstExecSpan(sc trace.SpanContext) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	if !op.firstExec.IsValid() {
+		op.firstExec = sc
+	}
+}
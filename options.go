@@ -0,0 +1,664 @@
+package otsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/api/metric"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/label"
+)
+
+type config struct {
+	tracer                 trace.Tracer
+	tracerProvider         trace.Provider
+	tracerFromContext      func(context.Context) trace.Tracer
+	instrumentationVersion string
+	lifecycle              *lifecycle
+
+	meter                   metric.Meter
+	metrics                 *instruments
+	metricLabelsFromContext func(context.Context) []label.KeyValue
+	metricsFlush            func(context.Context) error
+
+	deniedOps      map[string]bool
+	spanNamePrefix string
+	requestIDKey   interface{}
+	requestIDAttr  label.Key
+	routeKey       interface{}
+	commonAttrs    []label.KeyValue
+	spanStartHook  func(ctx context.Context, op string) []label.KeyValue
+	spanEndHook    func(span trace.Span, err error)
+	beforeCall     func(ctx context.Context, op, query string) context.Context
+	afterCall      func(ctx context.Context, op string, err error, dur time.Duration)
+	durationAttr   bool
+	errorOnly      bool
+	goroutineID    bool
+	errorAttrKey   label.Key
+	errorRecording ErrorRecording
+
+	driverName         string
+	dsnAutoParse       bool
+	serverConnIDFunc   func(driver.Conn) (int64, bool)
+	serverVersionQuery string
+
+	sessionTraceVar string
+	schemaTracking  bool
+	batchLinks      bool
+
+	argsMode       ArgsMode
+	argsKey        label.Key
+	argsReadsOnly  bool
+	maxArgsLength  int
+	maxArgsCount   int
+	argsBytes      bool
+	argValueFormat func(driver.Value) (string, bool)
+	redactedParams map[string]bool
+
+	traceArgRejections bool
+
+	tokenizer            *SQLTokenizer
+	statementOnErrorOnly bool
+	maxStatementLength   int
+	recompileEvents      bool
+	eagerResultMetadata  bool
+	multiStatements      bool
+	dualStatement        bool
+	obfuscate            bool
+	placeholderCount     bool
+	interpolate          bool
+	queryHash            bool
+	paramTypes           bool
+	fullStatementAudit   func(ctx context.Context, query string, args bool)
+
+	autoExplainRate    float64
+	slowQueryThreshold time.Duration
+	opTimeout          time.Duration
+	pingCoalescer      *pingCoalescer
+	connAge            bool
+	connMaxLifetime    time.Duration
+
+	maxRowSpans int
+	rowsSampler RowsSampler
+}
+
+// Option configures how a wrapped driver is instrumented.
+type Option func(*config)
+
+func newConfig(tracer trace.Tracer, opts []Option) *config {
+	cfg := &config{
+		tracer:                 tracer,
+		instrumentationVersion: InstrumentationVersion,
+		argsKey:                argsLbl,
+		tokenizer:              &GenericTokenizer,
+		slowQueryThreshold:     defaultSlowQueryThreshold,
+		lifecycle:              newLifecycle(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.tracerProvider != nil {
+		cfg.tracer = cfg.tracerProvider.Tracer(InstrumentationName,
+			trace.WithInstrumentationVersion(cfg.instrumentationVersion))
+	}
+	cfg.metrics = newInstruments(cfg.meter)
+	return cfg
+}
+
+// WithSessionTraceVar sets the session variable name to the W3C traceparent
+// of the current span whenever database/sql resets a pooled connection
+// before handing it out, by issuing `SET <name> = '<traceparent>'`.
+//
+// This is meant for Postgres, where setting `application_name` or a custom
+// GUC (e.g. `myapp.traceparent`) makes the trace visible in server logs and
+// pg_stat_activity without modifying the query text. Other dialects may not
+// support the statement. Connections that were just opened are not reset by
+// database/sql and so don't carry the variable until their first reuse.
+func WithSessionTraceVar(name string) Option {
+	return func(cfg *config) {
+		cfg.sessionTraceVar = name
+	}
+}
+
+// WithSchemaTracking records db.schema on the spans of a conn, the schema
+// its session was last switched to by a statement passing through the
+// conn's Exec: `SET search_path TO ...` for Postgres, `USE ...` for MySQL.
+// It's approximate: schemas set in the DSN, by functions or by statements
+// run through prepared statements or queries go unnoticed, and so does a
+// `SET LOCAL` ending with its transaction.
+func WithSchemaTracking() Option {
+	return func(cfg *config) {
+		cfg.schemaTracking = true
+	}
+}
+
+// WithBatchLinks links every exec span made under the same WithOperationName
+// context to the first exec span of that operation, so backends can show
+// them as related without nesting them. Off by default since large batches
+// produce many links.
+func WithBatchLinks() Option {
+	return func(cfg *config) {
+		cfg.batchLinks = true
+	}
+}
+
+// WithMaxArgsLength caps the length of the args attribute to n bytes.
+func WithMaxArgsLength(n int) Option {
+	return func(cfg *config) {
+		cfg.maxArgsLength = n
+	}
+}
+
+// WithMaxStatementLength caps the length of the statement attribute to n
+// bytes.
+func WithMaxStatementLength(n int) Option {
+	return func(cfg *config) {
+		cfg.maxStatementLength = n
+	}
+}
+
+// WithMaxArgsCount formats at most n arguments in the args attribute, the
+// remaining ones being summarized as "...(N more)". Useful for bulk inserts
+// with thousands of parameters.
+func WithMaxArgsCount(n int) Option {
+	return func(cfg *config) {
+		cfg.maxArgsCount = n
+	}
+}
+
+// WithAutoExplain runs EXPLAIN for the given fraction (0 to 1) of the SELECT
+// queries slower than the slow query threshold, and records the resulting
+// plan as db.plan on a sql-explain span, child of the query span.
+//
+// The EXPLAIN runs in the background on a new connection opened with the
+// same DSN, after the query span ended. The plan can't be added to the
+// query span as an event then, so it goes on a span of its own, which also
+// times the EXPLAIN and records its failures. This doubles the work of the
+// sampled queries and the plan output is dialect-specific: keep the rate
+// low. Off by default.
+func WithAutoExplain(sampleRate float64) Option {
+	return func(cfg *config) {
+		cfg.autoExplainRate = sampleRate
+	}
+}
+
+// WithSlowQueryThreshold sets the duration above which a query is
+// considered slow. Defaults to 1s.
+func WithSlowQueryThreshold(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.slowQueryThreshold = d
+	}
+}
+
+// WithConnAge records how long ago the connection of each call was opened
+// as db.connection.age_ms, to correlate latency with connections being
+// recycled. Given the max lifetime set with sql.DB.SetConnMaxLifetime, the
+// close of a connection that reached it is traced with a sql-conn-expired
+// event; pass 0 when it isn't set.
+func WithConnAge(maxLifetime time.Duration) Option {
+	return func(cfg *config) {
+		cfg.connAge = true
+		cfg.connMaxLifetime = maxLifetime
+	}
+}
+
+// WithPingCoalescing traces and records metrics for at most one ping per
+// window, across all the connections of the driver, to quiet health checks
+// that ping the pool in bursts. The next traced ping counts the ones left
+// out as db.ping.coalesced. Failed pings are still counted by the ping
+// failures metric.
+func WithPingCoalescing(window time.Duration) Option {
+	return func(cfg *config) {
+		cfg.pingCoalescer = &pingCoalescer{window: window}
+	}
+}
+
+// WithTracerFromContext resolves the tracer to use from the context of each
+// call, e.g. to route the traces of each tenant to its own provider. When the
+// resolver returns nil, the tracer given to WrapDriver is used.
+func WithTracerFromContext(resolve func(ctx context.Context) trace.Tracer) Option {
+	return func(cfg *config) {
+		cfg.tracerFromContext = resolve
+	}
+}
+
+// WithTracerProvider derives the tracer from provider, named after this
+// library, instead of using the tracer given to WrapDriver.
+func WithTracerProvider(provider trace.Provider) Option {
+	return func(cfg *config) {
+		cfg.tracerProvider = provider
+	}
+}
+
+// WithInstrumentationVersion overrides the instrumentation version reported
+// by the tracer derived with WithTracerProvider, e.g. for forks.
+func WithInstrumentationVersion(version string) Option {
+	return func(cfg *config) {
+		cfg.instrumentationVersion = version
+	}
+}
+
+// WithStatementOnErrorOnly records the query attribute only on spans of
+// calls that failed, keeping potentially sensitive SQL off the spans of
+// successful calls.
+func WithStatementOnErrorOnly() Option {
+	return func(cfg *config) {
+		cfg.statementOnErrorOnly = true
+	}
+}
+
+// WithMeter records metrics about the wrapped driver on meter.
+func WithMeter(meter metric.Meter) Option {
+	return func(cfg *config) {
+		cfg.meter = meter
+	}
+}
+
+// WithMetricsFlush sets how Instrumentation.Flush and Shutdown export the
+// metrics buffered by the meter, e.g. by collecting and exporting through
+// the SDK controller behind the meter. The API meter has no way to flush
+// by itself.
+func WithMetricsFlush(flush func(ctx context.Context) error) Option {
+	return func(cfg *config) {
+		cfg.metricsFlush = flush
+	}
+}
+
+// WithSpanNamePrefix prepends prefix to the name of every span, e.g.
+// "users-db." for "users-db.sql-conn-query", to tell apart the databases of
+// a service wrapping several drivers. Options taking operation names, such
+// as WithDeniedOperations, still take them without the prefix.
+func WithSpanNamePrefix(prefix string) Option {
+	return func(cfg *config) {
+		cfg.spanNamePrefix = prefix
+	}
+}
+
+// WithDeniedOperations disables the spans of the given operations, named
+// after their spans, e.g. "sql-conn-reset" or "sql-rows-next". Metrics are
+// still recorded for them.
+func WithDeniedOperations(ops ...string) Option {
+	return func(cfg *config) {
+		if cfg.deniedOps == nil {
+			cfg.deniedOps = make(map[string]bool, len(ops))
+		}
+		for _, op := range ops {
+			cfg.deniedOps[op] = true
+		}
+	}
+}
+
+// WithSQLTokenizer sets how statements are lexed by the features parsing
+// them, to the dialect of the driver. Defaults to GenericTokenizer.
+func WithSQLTokenizer(tokenizer SQLTokenizer) Option {
+	return func(cfg *config) {
+		cfg.tokenizer = &tokenizer
+	}
+}
+
+// WithMultiStatementDetection records db.statement.count and
+// db.multistatement on the spans of statements made of several statements
+// separated by semicolons, which drivers may allow and which change how
+// results and errors behave. It's opt-in since it parses every statement.
+func WithMultiStatementDetection() Option {
+	return func(cfg *config) {
+		cfg.multiStatements = true
+	}
+}
+
+// WithRecompileEvents records a sql-stmt-recompile event on the spans of
+// calls failing because the server invalidated the plan of a prepared
+// statement, to chase plan cache churn. Only the errors of Postgres
+// ("cached plan must not change result type") and MySQL (error 1615) are
+// recognized.
+func WithRecompileEvents() Option {
+	return func(cfg *config) {
+		cfg.recompileEvents = true
+	}
+}
+
+// WithEagerResultMetadata records the rows affected and the last insert id
+// of each successful exec on its span, as db.rows_affected and
+// db.last_insert_id, whether or not the caller inspects the result. Values
+// the driver doesn't support are left out.
+func WithEagerResultMetadata() Option {
+	return func(cfg *config) {
+		cfg.eagerResultMetadata = true
+	}
+}
+
+// WithoutTransactionSpans disables the spans of beginning, committing and
+// rolling back transactions, for workloads of many tiny transactions where
+// they'd triple the span count. It's shorthand for denying these operations
+// with WithDeniedOperations.
+func WithoutTransactionSpans() Option {
+	return WithDeniedOperations("sql-tx-begin", "sql-tx-commit", "sql-tx-rollback")
+}
+
+// WithDSNAutoParse records the server address, database name and user found
+// in the DSN of each connection on its spans. See the dsn package for the
+// supported drivers: the driver is identified by the name suffix given to
+// WrapDriver.
+func WithDSNAutoParse() Option {
+	return func(cfg *config) {
+		cfg.dsnAutoParse = true
+	}
+}
+
+// WithCommonAttributes adds attrs to every span, e.g. the datastore cluster
+// name, on top of the resource attributes of the tracer provider.
+func WithCommonAttributes(attrs ...label.KeyValue) Option {
+	return func(cfg *config) {
+		cfg.commonAttrs = append(cfg.commonAttrs, attrs...)
+	}
+}
+
+// WithArgValueFormatter renders each argument value with format in the args
+// attribute and the interpolated statement, e.g. to show domain types
+// nicely or redact them. Values for which format returns false get the
+// default formatting.
+func WithArgValueFormatter(format func(driver.Value) (string, bool)) Option {
+	return func(cfg *config) {
+		cfg.argValueFormat = format
+	}
+}
+
+// WithArgsAttributeKey records the formatted arguments of calls under key,
+// e.g. "db.statement.parameters", instead of "args".
+func WithArgsAttributeKey(key string) Option {
+	return func(cfg *config) {
+		cfg.argsKey = label.Key(key)
+	}
+}
+
+// WithArgsCaptureForReadsOnly records the arguments of reads only, i.e.
+// SELECT and SHOW statements, writes being the most likely to carry
+// sensitive values. Statements whose verb can't be told, e.g. CTEs starting
+// with WITH, are treated as writes.
+func WithArgsCaptureForReadsOnly() Option {
+	return func(cfg *config) {
+		cfg.argsReadsOnly = true
+	}
+}
+
+// WithArgsBytes records an estimate of the bytes the arguments of execs
+// weigh as db.statement.args_bytes, summing the lengths of strings and byte
+// slices and the sizes of other values, to spot oversized writes. Only the
+// first 1000 arguments of a call are counted.
+func WithArgsBytes() Option {
+	return func(cfg *config) {
+		cfg.argsBytes = true
+	}
+}
+
+// WithRedactedParamNames masks the values of the named parameters called
+// after one of names, compared case-insensitively, e.g. "password" or "ssn",
+// in the args attribute and the interpolated statement. It only applies to
+// named parameters, not positional ones.
+func WithRedactedParamNames(names ...string) Option {
+	return func(cfg *config) {
+		if cfg.redactedParams == nil {
+			cfg.redactedParams = make(map[string]bool, len(names))
+		}
+		for _, name := range names {
+			cfg.redactedParams[strings.ToLower(name)] = true
+		}
+	}
+}
+
+// WithArgsMode sets how the arguments of calls are recorded. Defaults to
+// ArgsValues.
+func WithArgsMode(mode ArgsMode) Option {
+	return func(cfg *config) {
+		cfg.argsMode = mode
+	}
+}
+
+// WithSpanStartHook calls hook before starting each span, with the operation
+// name (the span name), and adds the attributes it returns to the span. The
+// hook runs on the query path and must be fast.
+func WithSpanStartHook(hook func(ctx context.Context, op string) []label.KeyValue) Option {
+	return func(cfg *config) {
+		cfg.spanStartHook = hook
+	}
+}
+
+// WithSpanEndHook calls hook right before ending each span, with the error
+// the call returned if any, so it can inspect or annotate the span. The hook
+// runs on the query path and must be fast.
+func WithSpanEndHook(hook func(span trace.Span, err error)) Option {
+	return func(cfg *config) {
+		cfg.spanEndHook = hook
+	}
+}
+
+// WithBeforeCall calls hook before each driver call, with the operation name
+// and the statement of the call, if any. The call proceeds with the context
+// the hook returns. The hook runs synchronously on the query path, so it can
+// delay or cancel calls, e.g. to inject faults in chaos tests.
+func WithBeforeCall(hook func(ctx context.Context, op, query string) context.Context) Option {
+	return func(cfg *config) {
+		cfg.beforeCall = hook
+	}
+}
+
+// WithAfterCall calls hook after each driver call, with the operation name,
+// the error the call returned if any and how long it took. The hook runs
+// synchronously on the query path and must be fast.
+func WithAfterCall(hook func(ctx context.Context, op string, err error, dur time.Duration)) Option {
+	return func(cfg *config) {
+		cfg.afterCall = hook
+	}
+}
+
+// WithFullStatementAudit calls hook each time a span records its statement
+// in full through WithFullStatement, with the statement and whether the
+// arguments were recorded too, e.g. to write an audit log. The hook runs
+// synchronously on the query path.
+func WithFullStatementAudit(hook func(ctx context.Context, query string, args bool)) Option {
+	return func(cfg *config) {
+		cfg.fullStatementAudit = hook
+	}
+}
+
+// WithQueryHash records a stable fingerprint of the statement as
+// db.statement.hash, to group calls by statement even when the statement
+// itself isn't recorded. Statements differing only by their literals or
+// whitespace share a fingerprint.
+func WithQueryHash() Option {
+	return func(cfg *config) {
+		cfg.queryHash = true
+	}
+}
+
+// WithObfuscatedStatement records the statement of calls with its literals
+// replaced by `?`, e.g. `UPDATE users SET ssn = ? WHERE id = ?`, and only
+// that, keeping the values written in the statement off the spans. It takes
+// precedence over WithInterpolatedStatement and WithDualStatement.
+func WithObfuscatedStatement() Option {
+	return func(cfg *config) {
+		cfg.obfuscate = true
+	}
+}
+
+// WithDualStatement records the statement of calls with its literals
+// replaced by `?` as db.statement, to group calls by statement, and the
+// statement as otherwise recorded as db.statement.raw, to drill down into
+// one. Both are truncated to the max statement length.
+func WithDualStatement() Option {
+	return func(cfg *config) {
+		cfg.dualStatement = true
+	}
+}
+
+// WithPlaceholderCount records the number of parameters the placeholders of
+// the statement take as db.statement.placeholder_count, to compare against
+// the arguments of the call. It costs a lexing of every statement.
+func WithPlaceholderCount() Option {
+	return func(cfg *config) {
+		cfg.placeholderCount = true
+	}
+}
+
+// WithParamTypes records the parameter types of prepared statements as
+// db.statement.param_types, for drivers whose statements report them through
+// a `ParamTypes() []string` method. Other drivers are left alone.
+func WithParamTypes() Option {
+	return func(cfg *config) {
+		cfg.paramTypes = true
+	}
+}
+
+// WithArgRejectionTracing traces the arguments that the driver rejects when
+// checking them, with the index and type of the argument, since the error
+// drivers return for them rarely says which one it is. Off by default.
+func WithArgRejectionTracing() Option {
+	return func(cfg *config) {
+		cfg.traceArgRejections = true
+	}
+}
+
+// WithDurationAttribute records the duration of each call as db.duration_ms
+// on its span, for pipelines that log spans as flat records and don't see
+// their start and end times.
+func WithDurationAttribute() Option {
+	return func(cfg *config) {
+		cfg.durationAttr = true
+	}
+}
+
+// WithServerConnectionID extracts the id the server knows a connection by,
+// recorded as db.server.connection_id on its spans, for drivers that aren't
+// supported out of the box. pgx's stdlib connections are supported, and so
+// are the connections with a `PID() uint32` method.
+func WithServerConnectionID(extract func(conn driver.Conn) (id int64, ok bool)) Option {
+	return func(cfg *config) {
+		cfg.serverConnIDFunc = extract
+	}
+}
+
+// WithRequestIDFromContext records the value stored in the context of each
+// call under key, e.g. a request id, as the attribute attr, to correlate DB
+// spans with application logs. Values that aren't a string or a
+// fmt.Stringer are skipped.
+func WithRequestIDFromContext(key interface{}, attr string) Option {
+	return func(cfg *config) {
+		cfg.requestIDKey = key
+		cfg.requestIDAttr = label.Key(attr)
+	}
+}
+
+// WithRouteFromContext records the HTTP route stored in the context of each
+// call under key, e.g. by the router middleware, as http.route, to break
+// down DB time by endpoint. Calls without a route are left alone.
+func WithRouteFromContext(key interface{}) Option {
+	return func(cfg *config) {
+		cfg.routeKey = key
+	}
+}
+
+// WithServerVersion records the version of the server on the spans of each
+// connection as db.version, found once per connection when it's opened,
+// by running query, e.g. "SELECT version()" for Postgres and MySQL or
+// "SELECT sqlite_version()" for SQLite. Connections exposing a
+// ServerVersion() string method are asked instead. The attribute is left
+// out when the version can't be found.
+func WithServerVersion(query string) Option {
+	return func(cfg *config) {
+		cfg.serverVersionQuery = query
+	}
+}
+
+// WithErrorAttributeKey also records the message of errors as an attribute
+// named key, e.g. the legacy "err", on top of the exception event of the
+// OpenTelemetry conventions.
+func WithErrorAttributeKey(key string) Option {
+	return func(cfg *config) {
+		cfg.errorAttrKey = label.Key(key)
+	}
+}
+
+// WithErrorOnlyTracing only creates the spans of calls that fail, relying
+// on metrics for the calls that succeed. The span of a call is buffered
+// while it runs and started, backdated, when it returns an error, so it's
+// never exported otherwise. Calls still pay for formatting the attributes,
+// and the driver sees no span in the context of the call.
+func WithErrorOnlyTracing() Option {
+	return func(cfg *config) {
+		cfg.errorOnly = true
+	}
+}
+
+// WithGoroutineID records the id of the goroutine making each call as
+// db.goroutine_id, to tell apart the chains of calls of concurrent
+// goroutines when diagnosing contention. Go doesn't expose goroutine ids:
+// they're parsed from a stack trace taken on every call, which is slow.
+// Only enable it while debugging.
+func WithGoroutineID() Option {
+	return func(cfg *config) {
+		cfg.goroutineID = true
+	}
+}
+
+// WithErrorRecording sets how the errors of calls are recorded on their
+// spans. Defaults to ErrorEventAndStatus.
+func WithErrorRecording(mode ErrorRecording) Option {
+	return func(cfg *config) {
+		cfg.errorRecording = mode
+	}
+}
+
+// WithOperationTimeout bounds every prepare, exec and query by d, on top of
+// the deadline of the caller's context, as a safety net against runaway
+// queries. For queries, the bound covers reading the rows too. Beware that
+// it cuts off legitimately long operations as well.
+func WithOperationTimeout(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.opTimeout = d
+	}
+}
+
+// WithInterpolatedStatement records the statement with the placeholders
+// replaced by the quoted values of the arguments, for copy-pasting while
+// debugging. The rendering is best effort and mustn't be relied upon to be
+// executable. It's only done when the args mode records values.
+//
+// The placeholder style detected in the statement, e.g. "?" or "$1", is
+// recorded as db.statement.placeholder_style.
+func WithInterpolatedStatement() Option {
+	return func(cfg *config) {
+		cfg.interpolate = true
+	}
+}
+
+// WithMetricAttributesFromContext adds the labels returned by labels to the
+// metrics of each call, e.g. a tenant tier stored in the context.
+//
+// Every distinct set of labels creates new time series: only return labels
+// with a small, bounded set of values, never ids or free-form text.
+func WithMetricAttributesFromContext(labels func(ctx context.Context) []label.KeyValue) Option {
+	return func(cfg *config) {
+		cfg.metricLabelsFromContext = labels
+	}
+}
+
+// WithMaxRowSpans creates spans for the first n calls to Next of each set
+// of rows only, bounding the number of spans of large reads. The calls past
+// n are counted instead, and the count recorded when the rows are closed.
+func WithMaxRowSpans(n int) Option {
+	return func(cfg *config) {
+		cfg.maxRowSpans = n
+	}
+}
+
+// WithRowsSampler decides which calls to Next get a span using sampler
+// rather than the sampling decision of the query, to trace row streaming
+// in detail for a few unsampled queries or to drop its spans from sampled
+// ones.
+func WithRowsSampler(sampler RowsSampler) Option {
+	return func(cfg *config) {
+		cfg.rowsSampler = sampler
+	}
+}
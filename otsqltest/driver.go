@@ -0,0 +1,93 @@
+package otsqltest
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+)
+
+// Driver is a trivial in-memory driver.Driver, to exercise traced code
+// without a database, e.g. in benchmarks. Every exec affects one row and
+// every query returns Rows, whatever the statement.
+type Driver struct {
+	Columns []string
+	Rows    [][]driver.Value
+}
+
+// Open implements driver.Driver.
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	return &conn{d: d}, nil
+}
+
+type conn struct {
+	d *Driver
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{c: c}, nil
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return &stmt{c: c}, nil
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) { return tx{}, nil }
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return tx{}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return &rows{d: c.d}, nil
+}
+
+type stmt struct {
+	c *conn
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &rows{d: s.c.d}, nil
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return &rows{d: s.c.d}, nil
+}
+
+type tx struct{}
+
+func (tx) Commit() error   { return nil }
+func (tx) Rollback() error { return nil }
+
+type rows struct {
+	d    *Driver
+	next int
+}
+
+func (r *rows) Columns() []string { return r.d.Columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.d.Rows) {
+		return io.EOF
+	}
+	copy(dest, r.d.Rows[r.next])
+	r.next++
+	return nil
+}
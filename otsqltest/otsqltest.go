@@ -0,0 +1,153 @@
+// Package otsqltest records the spans of code using otsql, so tests can
+// assert what their database calls are traced as.
+//
+//	rec := otsqltest.NewRecorder()
+//	name, err := otsql.WrapDriver("test", drv, rec.Tracer())
+//	...
+//	db, err := sql.Open(name, dsn)
+//	...
+//	for _, span := range rec.Named("sql-conn-query") {
+//	    if span.Attr("query") != wantQuery {
+//	        t.Errorf(...)
+//	    }
+//	}
+package otsqltest
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/api/trace/tracetest"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Span is a snapshot of an ended span.
+type Span struct {
+	Name         string
+	SpanContext  trace.SpanContext
+	ParentSpanID trace.SpanID
+	Attributes   map[string]interface{}
+	Events       []Event
+	StatusCode   codes.Code
+}
+
+// Event is an event of a span.
+type Event struct {
+	Name       string
+	Attributes map[string]interface{}
+}
+
+// Attr returns the value of the attribute key, or nil if the span doesn't
+// have it.
+func (s Span) Attr(key string) interface{} {
+	return s.Attributes[key]
+}
+
+// HasEvent reports whether the span has an event called name.
+func (s Span) HasEvent(name string) bool {
+	for _, ev := range s.Events {
+		if ev.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Recorder provides a tracer recording the spans it starts in memory. It's
+// safe for concurrent use.
+type Recorder struct {
+	tracer trace.Tracer
+	sink   Sink
+
+	mu    sync.Mutex
+	ended []*tracetest.Span
+}
+
+// NewRecorder returns an empty recorder.
+func NewRecorder() *Recorder {
+	return NewRecorderWithSink(nil)
+}
+
+// NewRecorderWithSink returns an empty recorder that also tells sink of the
+// start and end of each span as they happen.
+func NewRecorderWithSink(sink Sink) *Recorder {
+	r := &Recorder{sink: sink}
+	r.tracer = tracetest.NewProvider(tracetest.WithSpanRecorder(r)).Tracer("otsqltest")
+	return r
+}
+
+// Tracer returns the tracer to wrap drivers with.
+func (r *Recorder) Tracer() trace.Tracer {
+	return r.tracer
+}
+
+// OnStart implements tracetest.SpanRecorder.
+func (r *Recorder) OnStart(span *tracetest.Span) {
+	if r.sink != nil {
+		r.sink.Observe(sinkEvent(SpanStarted, span))
+	}
+}
+
+// OnEnd implements tracetest.SpanRecorder. The span is still locked while
+// it's ended, so it's only snapshotted when the spans are asked for.
+func (r *Recorder) OnEnd(span *tracetest.Span) {
+	r.mu.Lock()
+	r.ended = append(r.ended, span)
+	r.mu.Unlock()
+	if r.sink != nil {
+		r.sink.Observe(sinkEvent(SpanEnded, span))
+	}
+}
+
+// Spans returns the spans ended so far, in the order they ended.
+func (r *Recorder) Spans() []Span {
+	r.mu.Lock()
+	ended := make([]*tracetest.Span, len(r.ended))
+	copy(ended, r.ended)
+	r.mu.Unlock()
+
+	spans := make([]Span, 0, len(ended))
+	for _, span := range ended {
+		spans = append(spans, snapshot(span))
+	}
+	return spans
+}
+
+func snapshot(span *tracetest.Span) Span {
+	snap := Span{
+		Name:         span.Name(),
+		SpanContext:  span.SpanContext(),
+		ParentSpanID: span.ParentSpanID(),
+		Attributes:   make(map[string]interface{}),
+		StatusCode:   span.StatusCode(),
+	}
+	for key, value := range span.Attributes() {
+		snap.Attributes[string(key)] = value.AsInterface()
+	}
+	for _, ev := range span.Events() {
+		event := Event{Name: ev.Name, Attributes: make(map[string]interface{})}
+		for key, value := range ev.Attributes {
+			event.Attributes[string(key)] = value.AsInterface()
+		}
+		snap.Events = append(snap.Events, event)
+	}
+	return snap
+}
+
+// Named returns the spans ended so far called name.
+func (r *Recorder) Named(name string) []Span {
+	var spans []Span
+	for _, span := range r.Spans() {
+		if span.Name == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// Reset forgets the spans recorded so far.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ended = nil
+}
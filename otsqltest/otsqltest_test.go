@@ -0,0 +1,102 @@
+package otsqltest_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/aybabtme/otsql"
+	"github.com/aybabtme/otsql/otsqltest"
+)
+
+func openDB(t *testing.T, name string, rec *otsqltest.Recorder) *sql.DB {
+	t.Helper()
+	drv := &otsqltest.Driver{
+		Columns: []string{"id"},
+		Rows:    [][]driver.Value{{int64(1)}, {int64(2)}},
+	}
+	name, err := otsql.WrapDriver(name, drv, rec.Tracer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(name, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRecorder(t *testing.T) {
+	const query = "SELECT id FROM t"
+
+	rec := otsqltest.NewRecorder()
+	db := openDB(t, "otsqltest-recorder", rec)
+
+	rows, err := db.QueryContext(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for rows.Next() {
+		n++
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("got %d rows, want 2", n)
+	}
+
+	spans := rec.Named("sql-conn-query")
+	if len(spans) != 1 {
+		t.Fatalf("got %d sql-conn-query spans, want 1", len(spans))
+	}
+	if got := spans[0].Attr("query"); got != query {
+		t.Errorf("query = %v, want %q", got, query)
+	}
+	if got := spans[0].Attr("missing"); got != nil {
+		t.Errorf("missing = %v, want nil", got)
+	}
+	if len(rec.Spans()) < 1 {
+		t.Error("Spans is missing the query span")
+	}
+
+	rec.Reset()
+	if spans := rec.Spans(); len(spans) != 0 {
+		t.Errorf("got %d spans after Reset, want 0", len(spans))
+	}
+}
+
+func TestRecorderWithSink(t *testing.T) {
+	var tl otsqltest.Timeline
+	rec := otsqltest.NewRecorderWithSink(&tl)
+	db := openDB(t, "otsqltest-sink", rec)
+
+	if _, err := db.ExecContext(context.Background(), "UPDATE t SET id = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var started, ended bool
+	for _, ev := range tl.Events() {
+		if ev.Name != "sql-conn-exec" {
+			continue
+		}
+		switch ev.Kind {
+		case otsqltest.SpanStarted:
+			started = true
+		case otsqltest.SpanEnded:
+			if !started {
+				t.Error("sql-conn-exec ended before it started")
+			}
+			ended = true
+		}
+	}
+	if !started || !ended {
+		t.Errorf("sql-conn-exec started %t and ended %t, want both", started, ended)
+	}
+	if open := tl.Open(); len(open) != 0 {
+		t.Errorf("got %d open spans, want 0", len(open))
+	}
+}
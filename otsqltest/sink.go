@@ -0,0 +1,108 @@
+package otsqltest
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/api/trace/tracetest"
+)
+
+// SinkEventKind tells the start of a span from its end.
+type SinkEventKind int
+
+const (
+	// SpanStarted is observed when a span starts.
+	SpanStarted SinkEventKind = iota
+	// SpanEnded is observed when a span ends.
+	SpanEnded
+)
+
+// SinkEvent is the start or end of a span.
+type SinkEvent struct {
+	Kind         SinkEventKind
+	Name         string
+	SpanContext  trace.SpanContext
+	ParentSpanID trace.SpanID
+}
+
+// Sink observes the spans of a recorder as they start and end, e.g. to
+// check how the spans of concurrent calls interleave. It's called
+// synchronously on the path of the traced calls, from as many goroutines
+// as there are calls in flight, so it must be safe for concurrent use.
+type Sink interface {
+	Observe(SinkEvent)
+}
+
+// sinkEvent describes span for a sink. It only reads the parts of the span
+// that aren't guarded by its lock, which is held while the span ends.
+func sinkEvent(kind SinkEventKind, span *tracetest.Span) SinkEvent {
+	return SinkEvent{
+		Kind:         kind,
+		Name:         span.Name(),
+		SpanContext:  span.SpanContext(),
+		ParentSpanID: span.ParentSpanID(),
+	}
+}
+
+// Timeline is a sink keeping the events it observes in order.
+type Timeline struct {
+	mu     sync.Mutex
+	events []SinkEvent
+}
+
+// Observe implements Sink.
+func (tl *Timeline) Observe(ev SinkEvent) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.events = append(tl.events, ev)
+}
+
+// Events returns the events observed so far, in order.
+func (tl *Timeline) Events() []SinkEvent {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	events := make([]SinkEvent, len(tl.events))
+	copy(events, tl.events)
+	return events
+}
+
+// Open returns the starts of the spans that didn't end yet, e.g. to check
+// that every span a call starts ends with it.
+func (tl *Timeline) Open() []SinkEvent {
+	var open []SinkEvent
+	for _, ev := range tl.Events() {
+		switch ev.Kind {
+		case SpanStarted:
+			open = append(open, ev)
+		case SpanEnded:
+			for i, started := range open {
+				if started.SpanContext.SpanID == ev.SpanContext.SpanID {
+					open = append(open[:i], open[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	return open
+}
+
+// MaxOverlap returns the most spans called name that were open at once,
+// e.g. to check that concurrent calls were traced concurrently.
+func (tl *Timeline) MaxOverlap(name string) int {
+	n, max := 0, 0
+	for _, ev := range tl.Events() {
+		if ev.Name != name {
+			continue
+		}
+		switch ev.Kind {
+		case SpanStarted:
+			n++
+			if n > max {
+				max = n
+			}
+		case SpanEnded:
+			n--
+		}
+	}
+	return max
+}
@@ -0,0 +1,36 @@
+package otsql
+
+import (
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/label"
+)
+
+// pingsCoalescedLbl is the number of pings left untraced since the previous
+// traced one.
+var pingsCoalescedLbl = label.Key("db.ping.coalesced")
+
+// pingCoalescer lets through at most one ping per window.
+type pingCoalescer struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	last      time.Time
+	coalesced int64
+}
+
+// admit reports whether a ping starting now is traced and, if so, how many
+// pings were coalesced since the previous traced one.
+func (p *pingCoalescer) admit() (bool, int64) {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.last.IsZero() && now.Sub(p.last) < p.window {
+		p.coalesced++
+		return false, 0
+	}
+	n := p.coalesced
+	p.last, p.coalesced = now, 0
+	return true, n
+}
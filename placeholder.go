@@ -0,0 +1,81 @@
+package otsql
+
+import (
+	"go.opentelemetry.io/otel/label"
+)
+
+var (
+	placeholderStyleLbl = label.Key("db.statement.placeholder_style")
+	placeholderCountLbl = label.Key("db.statement.placeholder_count")
+)
+
+// placeholderStyle is the notation of the placeholders of a statement, as
+// it's written: "?" (MySQL, SQLite), "$1" (Postgres), ":name" (Oracle) or
+// "@name" (SQL Server).
+type placeholderStyle string
+
+const (
+	styleUnknown  placeholderStyle = ""
+	styleQuestion placeholderStyle = "?"
+	styleDollar   placeholderStyle = "$1"
+	styleColon    placeholderStyle = ":name"
+	styleAt       placeholderStyle = "@name"
+)
+
+// detectPlaceholderStyle returns the style of the placeholders among
+// tokens, or styleUnknown when there are none. When styles are mixed, the
+// one least likely to be something else wins: a `?` next to `$1` is a
+// Postgres JSON operator, and an `@name` next to `?` a MySQL variable.
+func detectPlaceholderStyle(tokens []token) placeholderStyle {
+	seen := make(map[placeholderStyle]bool)
+	for _, tok := range tokens {
+		if style := tokenStyle(tok); style != styleUnknown {
+			seen[style] = true
+		}
+	}
+	for _, style := range []placeholderStyle{styleDollar, styleQuestion, styleColon, styleAt} {
+		if seen[style] {
+			return style
+		}
+	}
+	return styleUnknown
+}
+
+func tokenStyle(tok token) placeholderStyle {
+	switch {
+	case tok.kind == tokPositional && tok.text == "?":
+		return styleQuestion
+	case tok.kind == tokPositional:
+		return styleDollar
+	case tok.kind == tokNamed && tok.text[0] == ':':
+		return styleColon
+	case tok.kind == tokNamed:
+		return styleAt
+	}
+	return styleUnknown
+}
+
+// countPlaceholders returns the number of parameters the placeholders among
+// tokens stand for. Numbered and named placeholders repeated in the
+// statement count once, since they take the same argument.
+func countPlaceholders(tokens []token) int {
+	style := detectPlaceholderStyle(tokens)
+	if style == styleUnknown {
+		return 0
+	}
+	n := 0
+	seen := make(map[string]bool)
+	for _, tok := range tokens {
+		if tokenStyle(tok) != style {
+			continue
+		}
+		if style != styleQuestion {
+			if seen[tok.text] {
+				continue
+			}
+			seen[tok.text] = true
+		}
+		n++
+	}
+	return n
+}
@@ -0,0 +1,57 @@
+package otsql
+
+// AttributePolicy bundles the options controlling what the attributes of
+// spans disclose, to configure them in one place with WithAttributePolicy.
+// The zero value records everything, uncapped.
+type AttributePolicy struct {
+	// ArgsMode is how arguments are recorded, see WithArgsMode.
+	ArgsMode ArgsMode
+	// MaxArgsLength and MaxArgsCount cap the args attribute, see
+	// WithMaxArgsLength and WithMaxArgsCount. 0 means no limit.
+	MaxArgsLength int
+	MaxArgsCount  int
+	// MaxStatementLength caps the statement attribute, see
+	// WithMaxStatementLength. 0 means no limit.
+	MaxStatementLength int
+	// RedactedParamNames are the named parameters to mask, see
+	// WithRedactedParamNames.
+	RedactedParamNames []string
+	// InterpolateStatement records statements with their arguments, see
+	// WithInterpolatedStatement.
+	InterpolateStatement bool
+	// ObfuscateStatement records statements without their literals, see
+	// WithObfuscatedStatement.
+	ObfuscateStatement bool
+}
+
+var (
+	// StrictPrivacy records no argument values, and statements without
+	// their literals, capped to 2KiB, which keeps the attributes safe for
+	// most data.
+	StrictPrivacy = AttributePolicy{
+		ArgsMode:           ArgsOmitted,
+		MaxStatementLength: 2048,
+		ObfuscateStatement: true,
+	}
+	// FullDebug records everything, statements interpolated with their
+	// arguments, for local debugging.
+	FullDebug = AttributePolicy{
+		ArgsMode:             ArgsValues,
+		InterpolateStatement: true,
+	}
+)
+
+// WithAttributePolicy applies policy, overriding the options it covers
+// that were given before it.
+func WithAttributePolicy(policy AttributePolicy) Option {
+	return func(cfg *config) {
+		cfg.argsMode = policy.ArgsMode
+		cfg.maxArgsLength = policy.MaxArgsLength
+		cfg.maxArgsCount = policy.MaxArgsCount
+		cfg.maxStatementLength = policy.MaxStatementLength
+		cfg.redactedParams = nil
+		WithRedactedParamNames(policy.RedactedParamNames...)(cfg)
+		cfg.interpolate = policy.InterpolateStatement
+		cfg.obfuscate = policy.ObfuscateStatement
+	}
+}
@@ -0,0 +1,48 @@
+package otsql_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aybabtme/otsql"
+	"github.com/aybabtme/otsql/otsqltest"
+)
+
+func TestStrictPrivacyObfuscatesStatements(t *testing.T) {
+	db, rec := openTestDB(t, &otsqltest.Driver{}, otsql.WithAttributePolicy(otsql.StrictPrivacy))
+	if _, err := db.ExecContext(context.Background(), "UPDATE users SET ssn = '123-45-6789' WHERE id = ?", 42); err != nil {
+		t.Fatal(err)
+	}
+	spans := rec.Named("sql-conn-exec")
+	if len(spans) != 1 {
+		t.Fatalf("got %d sql-conn-exec spans, want 1", len(spans))
+	}
+	if got, want := spans[0].Attr("query"), "UPDATE users SET ssn = ? WHERE id = ?"; got != want {
+		t.Errorf("query = %v, want %q", got, want)
+	}
+	if got := spans[0].Attr("args"); got != nil {
+		t.Errorf("args = %v, want none", got)
+	}
+}
+
+func TestStrictPrivacyObfuscatesExplainedStatements(t *testing.T) {
+	rec := runAutoExplain(t, "test-explain-strict", "SELECT plan FROM t WHERE ssn = '123-45-6789'",
+		otsql.WithAttributePolicy(otsql.StrictPrivacy),
+	)
+	want := "SELECT plan FROM t WHERE ssn = ?"
+	for _, name := range []string{"sql-conn-query", "sql-explain"} {
+		spans := rec.Named(name)
+		if len(spans) != 1 {
+			t.Fatalf("got %d %s spans, want 1", len(spans), name)
+		}
+		if got := spans[0].Attr("query"); got != want {
+			t.Errorf("%s query = %v, want %q", name, got, want)
+		}
+		for key, value := range spans[0].Attributes {
+			if s, ok := value.(string); ok && strings.Contains(s, "123-45-6789") {
+				t.Errorf("%s %s = %q carries the literal", name, key, s)
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package otsql
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/api/trace"
+)
+
+// sqlStateError is implemented by the errors of Postgres drivers such as
+// pq and pgx.
+type sqlStateError interface {
+	SQLState() string
+}
+
+// recompileMessages are the messages of the errors drivers report when a
+// prepared statement had to be, or must be, recompiled.
+var recompileMessages = []string{
+	// Postgres, after a schema change, with SQLSTATE 0A000.
+	"cached plan must not change result type",
+	// MySQL error 1615.
+	"Prepared statement needs to be re-prepared",
+}
+
+// isRecompile reports whether err tells that the server invalidated the
+// plan of a prepared statement.
+func isRecompile(err error) bool {
+	var stateErr sqlStateError
+	if errors.As(err, &stateErr) && stateErr.SQLState() != "0A000" {
+		return false
+	}
+	msg := err.Error()
+	for _, m := range recompileMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordRecompile adds an event to span when err tells of a statement
+// recompilation, when enabled.
+func (cfg *config) recordRecompile(ctx context.Context, span trace.Span, err error) {
+	if cfg.recompileEvents && isRecompile(err) {
+		span.AddEvent(ctx, "sql-stmt-recompile", errLbl.String(err.Error()))
+	}
+}
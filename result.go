@@ -0,0 +1,37 @@
+package otsql
+
+import (
+	"database/sql/driver"
+
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/label"
+)
+
+var (
+	rowsAffectedLbl = label.Key("db.rows_affected")
+	lastInsertIDLbl = label.Key("db.last_insert_id")
+)
+
+// setResultAttrs records the metadata of the result of a successful exec
+// on its span, when eager result metadata is enabled. Drivers that don't
+// support a value, e.g. last insert ids on Postgres, return an error for
+// it: the value is then left out.
+func (cfg *config) setResultAttrs(span trace.Span, res driver.Result) {
+	if !cfg.eagerResultMetadata || res == nil || !span.IsRecording() {
+		return
+	}
+	if r, ok := res.(wrappedResult); ok {
+		res = r.parent
+	}
+
+	var attrs []label.KeyValue
+	if n, err := res.RowsAffected(); err == nil {
+		attrs = append(attrs, rowsAffectedLbl.Int64(n))
+	}
+	if id, err := res.LastInsertId(); err == nil {
+		attrs = append(attrs, lastInsertIDLbl.Int64(id))
+	}
+	if len(attrs) > 0 {
+		span.SetAttributes(attrs...)
+	}
+}
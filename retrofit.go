@@ -0,0 +1,39 @@
+package otsql
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel/api/trace"
+)
+
+var (
+	retrofitMu  sync.Mutex
+	retrofitSeq int
+)
+
+// TracedDriverName registers a traced version of the driver backing db and
+// returns the name it was registered under.
+//
+// A *sql.DB can't be instrumented in place since its driver is fixed when
+// it's opened. Instead, reopen it with the returned name and the same DSN:
+//
+//	name, err := otsql.TracedDriverName(db, tracer)
+//	if err != nil {
+//	    return errors.Wrap(err, "tracing DB")
+//	}
+//	traced, err := sql.Open(name, dsn)
+//
+// Every call registers a new driver, so call it once per *sql.DB.
+func TracedDriverName(db *sql.DB, tracer trace.Tracer, opts ...Option) (string, error) {
+	parent := db.Driver()
+	if _, ok := parent.(wrappedDriver); ok {
+		return "", ErrDriverAlreadyRegistered
+	}
+
+	retrofitMu.Lock()
+	defer retrofitMu.Unlock()
+	retrofitSeq++
+	return WrapDriver(fmt.Sprintf("%T-%d", parent, retrofitSeq), parent, tracer, opts...)
+}
@@ -0,0 +1,34 @@
+package otsql
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/label"
+)
+
+var (
+	dbRoleLbl    = label.Key("db.role")
+	dbReplicaLbl = label.Key("db.replica")
+)
+
+type dbRoleKey struct{}
+
+// WithDBRole returns a context declaring the role of the database the DB
+// calls made with it are routed to, e.g. "primary" or "replica", for apps
+// splitting reads and writes. The role is recorded on every span started
+// under the context, along with db.replica=true for "replica".
+func WithDBRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, dbRoleKey{}, role)
+}
+
+// dbRoleAttrs returns the attributes of the role declared in ctx, if any.
+func dbRoleAttrs(ctx context.Context) []label.KeyValue {
+	role, ok := ctx.Value(dbRoleKey{}).(string)
+	if !ok {
+		return nil
+	}
+	if role == "replica" {
+		return []label.KeyValue{dbRoleLbl.String(role), dbReplicaLbl.Bool(true)}
+	}
+	return []label.KeyValue{dbRoleLbl.String(role)}
+}
@@ -0,0 +1,89 @@
+package otsql
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/label"
+)
+
+var suppressedRowSpansLbl = label.Key("db.rows.suppressed_spans")
+
+// rowsState is the state of a wrappedRows, shared by its copies.
+type rowsState struct {
+	nexts      int64
+	suppressed int64
+	firstRow   int32
+}
+
+// spanNext counts a call to Next and reports whether it gets a span, given
+// at most max of them do. A max of 0 means no limit.
+func (st *rowsState) spanNext(max int) bool {
+	n := atomic.AddInt64(&st.nexts, 1)
+	if max <= 0 || n <= int64(max) {
+		return true
+	}
+	atomic.AddInt64(&st.suppressed, 1)
+	return false
+}
+
+// markFirstRow reports whether it's the first call for the rows, which
+// should be made when a row was read.
+func (st *rowsState) markFirstRow() bool {
+	return atomic.CompareAndSwapInt32(&st.firstRow, 0, 1)
+}
+
+// recordSuppressedSpans records how many calls to Next didn't get a span
+// because of the row spans cap, on a span of its own since the query span
+// has ended by the time the rows are read.
+func (r wrappedRows) recordSuppressedSpans() {
+	cfg := r.cfg()
+	suppressed := atomic.LoadInt64(&r.state.suppressed)
+	if suppressed == 0 {
+		return
+	}
+	_, span := cfg.startSpan(r.ctx, "sql-rows-close")
+	span.SetAttributes(suppressedRowSpansLbl.Int64(suppressed))
+	cfg.endSpan(span, nil)
+}
+
+// recordCanceled adds an event to the span of a call to Next when the
+// context of the query is done, e.g. a timeout hit while streaming a large
+// result.
+func (r wrappedRows) recordCanceled(ctx context.Context, span trace.Span) {
+	if err := r.ctx.Err(); err != nil {
+		span.AddEvent(ctx, "sql-rows-canceled", errLbl.String(err.Error()))
+	}
+}
+
+// RowsSampler decides whether a call to Next on rows read under ctx gets a
+// span, independently of whether the query itself is sampled.
+type RowsSampler func(ctx context.Context) bool
+
+// RowsSampleRatio returns a RowsSampler creating spans for the given
+// fraction, from 0 to 1, of the calls to Next.
+func RowsSampleRatio(ratio float64) RowsSampler {
+	return func(context.Context) bool {
+		return rand.Float64() < ratio
+	}
+}
+
+// sampleRowsNext consults the rows sampler, if any, and returns the options
+// to start the span of a call to Next with, or false if it gets no span.
+// Sampled calls under an unsampled query start a new trace linked to the
+// query, since their parent would otherwise have them dropped.
+func (cfg *config) sampleRowsNext(ctx context.Context) ([]trace.StartOption, bool) {
+	if cfg.rowsSampler == nil {
+		return nil, true
+	}
+	if !cfg.rowsSampler(ctx) {
+		return nil, false
+	}
+	parent := trace.SpanFromContext(ctx).SpanContext()
+	if !parent.IsValid() || parent.IsSampled() {
+		return nil, true
+	}
+	return []trace.StartOption{trace.WithNewRoot(), trace.LinkedTo(parent)}, true
+}
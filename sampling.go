@@ -0,0 +1,28 @@
+package otsql
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/label"
+)
+
+// samplingPriorityLbl is the tag Jaeger collectors force-sample spans on.
+var samplingPriorityLbl = label.Key("sampling.priority")
+
+type forceSampleKey struct{}
+
+// ForceSample returns a context whose DB spans carry sampling.priority=1,
+// the tag Jaeger force-samples spans on, so that they survive through a
+// Jaeger pipeline.
+//
+// The attribute is set when the spans start, so an OpenTelemetry sampler
+// sees it among the attributes it decides on, but whether the spans are
+// recorded in the first place is still up to the configured sampler.
+func ForceSample(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceSampleKey{}, true)
+}
+
+func forceSampled(ctx context.Context) bool {
+	forced, _ := ctx.Value(forceSampleKey{}).(bool)
+	return forced
+}
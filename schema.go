@@ -0,0 +1,87 @@
+package otsql
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/otel/label"
+)
+
+var schemaLbl = label.Key("db.schema")
+
+// trackSchema records the schema a successful exec of query switched the
+// session of the conn of info to, if it did, when schema tracking is on.
+func (cfg *config) trackSchema(info *connInfo, query string) {
+	if !cfg.schemaTracking {
+		return
+	}
+	if schema, ok := schemaFromStatement(cfg.tokenizer, query); ok {
+		info.mu.Lock()
+		defer info.mu.Unlock()
+		info.schema = schema
+	}
+}
+
+// schemaFromStatement returns the schema query switches the session to,
+// for the statements `SET [SESSION | LOCAL] search_path {TO | =} ...`,
+// `SET SCHEMA ...` and `USE ...`. Resetting the search path returns "".
+func schemaFromStatement(tz *SQLTokenizer, query string) (string, bool) {
+	var words []string
+	for _, tok := range tz.tokenize(query) {
+		switch tok.kind {
+		case tokSpace, tokComment, tokSemicolon:
+		case tokString, tokQuotedIdent:
+			words = append(words, unquote(tok.text))
+		default:
+			words = append(words, tok.text)
+		}
+	}
+	if len(words) < 2 {
+		return "", false
+	}
+
+	verb := strings.ToUpper(words[0])
+	switch {
+	case verb == "USE":
+		return strings.Join(words[1:], ""), true
+	case verb == "RESET" && strings.EqualFold(words[1], "search_path"):
+		return "", true
+	case verb != "SET":
+		return "", false
+	}
+	words = words[1:]
+	if len(words) > 0 && (strings.EqualFold(words[0], "SESSION") || strings.EqualFold(words[0], "LOCAL")) {
+		words = words[1:]
+	}
+	switch {
+	case len(words) > 1 && strings.EqualFold(words[0], "SCHEMA"):
+		return strings.Join(words[1:], ""), true
+	case len(words) > 2 && strings.EqualFold(words[0], "search_path") &&
+		(strings.EqualFold(words[1], "TO") || words[1] == "="):
+		return strings.Join(words[2:], ""), true
+	}
+	return "", false
+}
+
+// unquote strips the quotes of a quoted string or identifier, e.g. 'x',
+// "x", `x` or [x], and unescapes the doubled closing quotes within. Other
+// tokens, such as dollar-quoted strings, are returned as is.
+func unquote(text string) string {
+	var closer byte
+	switch text[0] {
+	case '\'', '"', '`':
+		closer = text[0]
+	case '[':
+		closer = ']'
+	default:
+		return text
+	}
+	s := text[1:]
+	if len(s) > 0 && s[len(s)-1] == closer {
+		// The closing quote ends an odd run of them, an even run being
+		// doubled quotes of an unterminated token.
+		if n := len(s) - len(strings.TrimRight(s, string(closer))); n%2 == 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	return strings.ReplaceAll(s, string([]byte{closer, closer}), string(closer))
+}
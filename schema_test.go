@@ -0,0 +1,30 @@
+package otsql
+
+import "testing"
+
+func TestSchemaFromStatement(t *testing.T) {
+	tests := []struct {
+		tz     *SQLTokenizer
+		query  string
+		want   string
+		wantOK bool
+	}{
+		{&SQLServerTokenizer, "USE [mydb]", "mydb", true},
+		{&SQLServerTokenizer, "USE [my]]db]", "my]db", true},
+		{&SQLServerTokenizer, "USE mydb;", "mydb", true},
+		{&MySQLTokenizer, "USE `my``db`", "my`db", true},
+		{&PostgresTokenizer, `SET search_path TO "my""schema"`, `my"schema`, true},
+		{&PostgresTokenizer, "SET SESSION search_path = 'app'", "app", true},
+		{&PostgresTokenizer, "SET LOCAL search_path TO app, public", "app,public", true},
+		{&PostgresTokenizer, "SET SCHEMA 'app'", "app", true},
+		{&PostgresTokenizer, "RESET search_path", "", true},
+		{&PostgresTokenizer, "SELECT 1", "", false},
+		{&PostgresTokenizer, "SET statement_timeout = 0", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := schemaFromStatement(tt.tz, tt.query)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("schemaFromStatement(%q) = %q, %t, want %q, %t", tt.query, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
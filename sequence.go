@@ -0,0 +1,28 @@
+package otsql
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/label"
+)
+
+var opSeqLbl = label.Key("db.op.seq")
+
+type opSequenceKey struct{}
+
+// WithOpSequence returns a context numbering the prepares, execs and queries
+// made with it, from 1, as db.op.seq, so that the spans of a request show the
+// order of its DB calls and how many there were, e.g. to spot N+1 queries.
+func WithOpSequence(ctx context.Context) context.Context {
+	return context.WithValue(ctx, opSequenceKey{}, new(int64))
+}
+
+// nextOpSeq returns the number of a call made with ctx, if ctx numbers them.
+func nextOpSeq(ctx context.Context) (int64, bool) {
+	seq, ok := ctx.Value(opSequenceKey{}).(*int64)
+	if !ok {
+		return 0, false
+	}
+	return atomic.AddInt64(seq, 1), true
+}
@@ -0,0 +1,46 @@
+package otsql
+
+import (
+	"database/sql/driver"
+	"reflect"
+
+	"go.opentelemetry.io/otel/label"
+)
+
+var serverConnIDLbl = label.Key("db.server.connection_id")
+
+// pider is implemented by connections exposing their Postgres backend PID,
+// such as *pgconn.PgConn.
+type pider interface {
+	PID() uint32
+}
+
+// serverConnID returns the id the server knows conn by, e.g. the backend PID
+// shown in pg_stat_activity, for the drivers exposing it.
+func (cfg *config) serverConnID(conn driver.Conn) (int64, bool) {
+	if cfg.serverConnIDFunc != nil {
+		if id, ok := cfg.serverConnIDFunc(conn); ok {
+			return id, true
+		}
+	}
+
+	if p, ok := conn.(pider); ok {
+		return int64(p.PID()), true
+	}
+	// pgx's stdlib conn: conn.Conn().PgConn().PID(), looked up by name to
+	// avoid depending on pgx.
+	v := reflect.ValueOf(conn)
+	for _, method := range []string{"Conn", "PgConn"} {
+		m := v.MethodByName(method)
+		if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+			return 0, false
+		}
+		if v = m.Call(nil)[0]; v.Kind() == reflect.Ptr && v.IsNil() {
+			return 0, false
+		}
+	}
+	if p, ok := v.Interface().(pider); ok {
+		return int64(p.PID()), true
+	}
+	return 0, false
+}
@@ -0,0 +1,28 @@
+package otsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+
+	"go.opentelemetry.io/otel/api/trace"
+)
+
+// setSessionTraceVar stores the traceparent of the span in ctx in the
+// session variable name. Failures are ignored: correlation is best
+// effort and must not make the connection unusable.
+func (c wrappedConn) setSessionTraceVar(ctx context.Context, name string) {
+	sc := trace.SpanFromContext(ctx).SpanContext()
+	if !sc.IsValid() {
+		return
+	}
+	traceparent := fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.TraceFlags)
+	query := fmt.Sprintf("SET %s = '%s'", name, traceparent)
+
+	switch parent := c.parent.(type) {
+	case driver.ExecerContext:
+		_, _ = parent.ExecContext(ctx, query, nil)
+	case driver.Execer:
+		_, _ = parent.Exec(query, nil)
+	}
+}
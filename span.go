@@ -0,0 +1,237 @@
+package otsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/semconv"
+)
+
+var (
+	// timeoutLbl is the time budget left to a call when it starts.
+	timeoutLbl  = label.Key("db.timeout_ms")
+	durationLbl = label.Key("db.duration_ms")
+	canceledLbl = label.Key("db.canceled")
+	timedOutLbl = label.Key("db.timeout")
+)
+
+// timedSpan remembers the call it traces and when it started.
+type timedSpan struct {
+	trace.Span
+	start time.Time
+	ctx   context.Context
+	op    string
+}
+
+// elapsed returns the time since the call traced by span started.
+func elapsed(span trace.Span) time.Duration {
+	if ts, ok := span.(*timedSpan); ok {
+		return time.Since(ts.start)
+	}
+	return 0
+}
+
+func unwrapSpan(span trace.Span) trace.Span {
+	if ts, ok := span.(*timedSpan); ok {
+		return ts.Span
+	}
+	return span
+}
+
+func (cfg *config) startSpan(ctx context.Context, name string, opts ...trace.StartOption) (context.Context, trace.Span) {
+	return cfg.startQuerySpan(ctx, name, "", opts...)
+}
+
+// startQuerySpan is startSpan for calls that run query.
+func (cfg *config) startQuerySpan(ctx context.Context, name, query string, opts ...trace.StartOption) (context.Context, trace.Span) {
+	if cfg.beforeCall != nil {
+		ctx = cfg.beforeCall(ctx, name, query)
+	}
+	// Calls are numbered whether or not they're traced, for the numbers to
+	// reflect the calls the app makes.
+	var seq int64
+	hasSeq := false
+	if query != "" {
+		seq, hasSeq = nextOpSeq(ctx)
+	}
+	start := time.Now()
+	if cfg.deniedOps[name] {
+		return ctx, &timedSpan{Span: trace.NoopSpan{}, start: start, ctx: ctx, op: name}
+	}
+	// All the attributes known at start go in a single option, rather than
+	// as many calls into the span as there are attributes.
+	attrs := append([]label.KeyValue{componentLbl.String("database/sql")}, cfg.commonAttrs...)
+	if cfg.spanStartHook != nil {
+		attrs = append(attrs, cfg.spanStartHook(ctx, name)...)
+	}
+	if op := operationFromContext(ctx); op != nil {
+		attrs = append(attrs, operationNameLbl.String(op.name))
+	}
+	if id, ok := cfg.requestID(ctx); ok {
+		attrs = append(attrs, cfg.requestIDAttr.String(id))
+	}
+	if route, ok := stringFromContext(ctx, cfg.routeKey); ok {
+		attrs = append(attrs, semconv.HTTPRouteKey.String(route))
+	}
+	if forceSampled(ctx) {
+		attrs = append(attrs, samplingPriorityLbl.Int(1))
+	}
+	if state, ok := circuitStateFromContext(ctx); ok {
+		attrs = append(attrs, circuitStateLbl.String(state))
+	}
+	attrs = append(attrs, dbRoleAttrs(ctx)...)
+	if cfg.goroutineID {
+		if id, ok := goroutineID(); ok {
+			attrs = append(attrs, goroutineIDLbl.Int64(id))
+		}
+	}
+	if hasSeq {
+		attrs = append(attrs, opSeqLbl.Int64(seq))
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		attrs = append(attrs, timeoutLbl.Int64(time.Until(deadline).Milliseconds()))
+	}
+	opts = append(opts, trace.WithAttributes(attrs...))
+	for _, sc := range spanLinksFromContext(ctx) {
+		opts = append(opts, trace.LinkedTo(sc))
+	}
+	if cfg.errorOnly {
+		return ctx, &timedSpan{Span: &deferredSpan{
+			tracer: cfg.tracerFor(ctx),
+			ctx:    ctx,
+			name:   cfg.spanNamePrefix + name,
+			opts:   opts,
+		}, start: start, ctx: ctx, op: name}
+	}
+	ctx, span := cfg.tracerFor(ctx).Start(ctx, cfg.spanNamePrefix+name, opts...)
+	return ctx, &timedSpan{Span: span, start: start, ctx: ctx, op: name}
+}
+
+// requestID returns the request id stored in ctx, if any.
+func (cfg *config) requestID(ctx context.Context) (string, bool) {
+	return stringFromContext(ctx, cfg.requestIDKey)
+}
+
+// stringFromContext returns the string or fmt.Stringer stored in ctx under
+// key, if any.
+func stringFromContext(ctx context.Context, key interface{}) (string, bool) {
+	if key == nil {
+		return "", false
+	}
+	switch v := ctx.Value(key).(type) {
+	case string:
+		return v, true
+	case fmt.Stringer:
+		return v.String(), true
+	}
+	return "", false
+}
+
+// tracerFor returns the tracer resolved from ctx, if any, or the configured
+// tracer.
+func (cfg *config) tracerFor(ctx context.Context) trace.Tracer {
+	if cfg.tracerFromContext != nil {
+		if tracer := cfg.tracerFromContext(ctx); tracer != nil {
+			return tracer
+		}
+	}
+	return cfg.tracer
+}
+
+// startExecSpan is startQuerySpan for exec operations, which get linked to
+// the first exec of their operation when batch links are enabled.
+func (cfg *config) startExecSpan(ctx context.Context, name, query string, opts ...trace.StartOption) (context.Context, trace.Span) {
+	op := operationFromContext(ctx)
+	if op == nil || !cfg.batchLinks {
+		return cfg.startQuerySpan(ctx, name, query, opts...)
+	}
+
+	if first, ok := op.firstExecSpan(); ok {
+		return cfg.startQuerySpan(ctx, name, query, append(opts, trace.LinkedTo(first))...)
+	}
+	ctx, span := cfg.startQuerySpan(ctx, name, query, opts...)
+	op.setFirstExecSpan(span.SpanContext())
+	return ctx, span
+}
+
+// endSpan ends the span of a call that returned err.
+func (cfg *config) endSpan(span trace.Span, err error) {
+	if ts, ok := span.(*timedSpan); ok && cfg.afterCall != nil {
+		defer func() { cfg.afterCall(ts.ctx, ts.op, err, time.Since(ts.start)) }()
+	}
+	if ds, ok := unwrapSpan(span).(*deferredSpan); ok {
+		ts := span.(*timedSpan)
+		real, failed := ds.materialize(ts.start, err)
+		if !failed {
+			return
+		}
+		span = &timedSpan{Span: real, start: ts.start, ctx: ts.ctx, op: ts.op}
+	}
+	if cfg.durationAttr {
+		span.SetAttributes(durationLbl.Float64(float64(elapsed(span)) / float64(time.Millisecond)))
+	}
+	if cfg.spanEndHook != nil {
+		cfg.spanEndHook(unwrapSpan(span), err)
+	}
+	span.End()
+}
+
+// ErrorRecording controls how the errors of calls surface on their spans.
+type ErrorRecording int
+
+const (
+	// ErrorEventAndStatus records an exception event and sets the span
+	// status. The default.
+	ErrorEventAndStatus ErrorRecording = iota
+	// ErrorStatusOnly sets the span status, with the error as its message,
+	// without recording an event.
+	ErrorStatusOnly
+	// ErrorEventOnly records an exception event, leaving the span status
+	// alone.
+	ErrorEventOnly
+)
+
+// recordError records the failure of a call on its span.
+func (cfg *config) recordError(ctx context.Context, span trace.Span, err error) {
+	if errors.Is(err, driver.ErrBadConn) {
+		// database/sql retries the call on another connection, so this
+		// isn't an error the caller sees.
+		span.AddEvent(ctx, "sql-bad-conn-retry")
+		return
+	}
+	cfg.recordRecompile(ctx, span, err)
+	code := recordContextError(ctx, span, err)
+	switch cfg.errorRecording {
+	case ErrorEventAndStatus:
+		span.RecordError(ctx, err, trace.WithErrorStatus(code))
+	case ErrorStatusOnly:
+		span.SetStatus(code, err.Error())
+	case ErrorEventOnly:
+		span.RecordError(ctx, err)
+	}
+	if cfg.errorAttrKey != "" {
+		span.SetAttributes(cfg.errorAttrKey.String(err.Error()))
+	}
+}
+
+// recordContextError tells apart calls the caller gave up on from calls
+// that ran out of time, and returns the status code matching err.
+func recordContextError(ctx context.Context, span trace.Span, err error) codes.Code {
+	switch {
+	case errors.Is(err, context.Canceled):
+		span.SetAttributes(canceledLbl.Bool(true))
+		span.AddEvent(ctx, "sql-canceled")
+		return codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		span.SetAttributes(timedOutLbl.Bool(true))
+		span.AddEvent(ctx, "sql-timeout")
+		return codes.DeadlineExceeded
+	}
+	return codes.Unknown
+}
@@ -4,67 +4,160 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"io"
+	"reflect"
+	"sync/atomic"
 
-	"github.com/kr/pretty"
+	"github.com/aybabtme/otsql/dsn"
 	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel/api/trace"
 	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/semconv"
 )
 
 var (
-	queryLbl = label.Key("query")
-	argsLbl  = label.Key("args")
+	componentLbl = label.Key("component")
+	queryLbl     = label.Key("query")
+	argsLbl      = label.Key("args")
+	errLbl       = label.Key("err")
+
+	preparedLbl = label.Key("db.statement.prepared")
+
+	// execerContextLbl and queryerContextLbl flag the calls falling back to
+	// the context-less Exec and Query of drivers.
+	execerContextLbl  = label.Key("db.execer_context")
+	queryerContextLbl = label.Key("db.queryer_context")
+
+	txIsolationLbl = label.Key("db.transaction.isolation")
+	txReadOnlyLbl  = label.Key("db.transaction.read_only")
 )
 
 type wrappedDriver struct {
-	tracer trace.Tracer
+	live   *liveConfig
 	parent driver.Driver
 }
 
 type wrappedConn struct {
-	tracer trace.Tracer
+	live   *liveConfig
+	info   *connInfo
 	parent driver.Conn
 }
 
 type wrappedTx struct {
-	tracer trace.Tracer
+	live   *liveConfig
+	info   *connInfo
 	ctx    context.Context
 	parent driver.Tx
 }
 
 type wrappedStmt struct {
-	tracer trace.Tracer
+	live   *liveConfig
+	info   *connInfo
+	conn   driver.Conn
 	ctx    context.Context
 	query  string
+	hash   *stmtHash
+	state  *stmtState
 	parent driver.Stmt
 }
 
 type wrappedResult struct {
-	tracer trace.Tracer
+	live   *liveConfig
 	ctx    context.Context
 	parent driver.Result
 }
 
 type wrappedRows struct {
-	tracer trace.Tracer
+	live   *liveConfig
 	ctx    context.Context
+	cancel context.CancelFunc
+	state  *rowsState
 	parent driver.Rows
 }
 
-func WrapDriver(nameSuffix string, driver driver.Driver, tracer trace.Tracer) string {
-	name := "traced-" + nameSuffix
-	d := wrappedDriver{parent: driver, tracer: tracer}
-	sql.Register(name, d)
-	return name
+// WrapDriver registers a traced version of driver and returns the name it's
+// registered under, to pass to sql.Open. It returns
+// ErrDriverAlreadyRegistered when a driver is already registered under that
+// name.
+func WrapDriver(nameSuffix string, driver driver.Driver, tracer trace.Tracer, opts ...Option) (string, error) {
+	inst, err := Instrument(nameSuffix, driver, tracer, opts...)
+	if err != nil {
+		return "", err
+	}
+	return inst.DriverName(), nil
 }
 
 func (d wrappedDriver) Open(name string) (driver.Conn, error) {
-	conn, err := d.parent.Open(name)
+	// database/sql gives no context to open connections with.
+	return d.open(context.Background(), name, func(context.Context) (driver.Conn, error) {
+		return d.parent.Open(name)
+	})
+}
+
+// open traces the opening of a connection to name with open, which is
+// given the context of the span.
+func (d wrappedDriver) open(ctx context.Context, name string, open func(context.Context) (driver.Conn, error)) (conn driver.Conn, err error) {
+	cfg := d.cfg()
+	ctx, span := cfg.startSpan(ctx, "sql-conn-open",
+		trace.WithAttributes(semconv.DBConnectionStringKey.String(dsn.Scrub(name))))
+	defer func() {
+		if err != nil {
+			cfg.recordError(ctx, span, err)
+		}
+		cfg.endSpan(span, err)
+	}()
+
+	conn, err = open(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return wrappedConn{tracer: d.tracer, parent: conn}, nil
+	return wrappedConn{live: d.live, info: newConnInfo(d, conn, name), parent: conn}, nil
+}
+
+func (c wrappedConn) ResetSession(ctx context.Context) (err error) {
+	cfg := c.cfg()
+	if cfg.sessionTraceVar != "" {
+		defer func(ctx context.Context) {
+			if err == nil {
+				c.setSessionTraceVar(ctx, cfg.sessionTraceVar)
+			}
+		}(ctx)
+	}
+
+	c.info.release()
+	cfg.metrics.resets.Add(ctx, 1, cfg.metricLabels(ctx)...)
+	ctx, span := cfg.startSpan(ctx, "sql-conn-reset", c.info.spanOption())
+	defer func() {
+		if err != nil {
+			cfg.recordError(ctx, span, err)
+		}
+		if errors.Is(err, driver.ErrBadConn) {
+			span.AddEvent(ctx, "sql-conn-discarded")
+		}
+		cfg.endSpan(span, err)
+	}()
+
+	if resetter, ok := c.parent.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid is called whenever database/sql returns the conn to the pool,
+// so it only gets a span when the conn is discarded.
+func (c wrappedConn) IsValid() bool {
+	validator, ok := c.parent.(driver.Validator)
+	if !ok || validator.IsValid() {
+		return true
+	}
+
+	cfg := c.cfg()
+
+	ctx, span := cfg.startSpan(c.info.spanContext(context.Background()), "sql-conn-invalid", c.info.spanOption())
+	span.AddEvent(ctx, "sql-conn-discarded")
+	cfg.endSpan(span, nil)
+	return false
 }
 
 func (c wrappedConn) Prepare(query string) (driver.Stmt, error) {
@@ -73,10 +166,27 @@ func (c wrappedConn) Prepare(query string) (driver.Stmt, error) {
 		return nil, err
 	}
 
-	return wrappedStmt{tracer: c.tracer, query: query, parent: parent}, nil
+	cfg := c.cfg()
+	cfg.trackPrepared(c.info)
+	return c.newStmt(context.Background(), query, cfg.newStmtHash(query), parent), nil
 }
 
 func (c wrappedConn) Close() error {
+	// Statements still open on a conn being closed were leaked by the code
+	// that prepared them.
+	cfg := c.cfg()
+	leaked := cfg.untrackPrepared(c.info, atomic.LoadInt64(&c.info.prepared))
+	expired := cfg.connExpired(c.info)
+	if leaked > 0 || expired {
+		ctx, span := cfg.startSpan(c.info.spanContext(context.Background()), "sql-conn-close", c.info.spanOption())
+		if leaked > 0 {
+			span.SetAttributes(stmtsLeakedLbl.Int64(leaked))
+		}
+		if expired {
+			span.AddEvent(ctx, "sql-conn-expired")
+		}
+		cfg.endSpan(span, nil)
+	}
 	return c.parent.Close()
 }
 
@@ -86,17 +196,24 @@ func (c wrappedConn) Begin() (driver.Tx, error) {
 		return nil, err
 	}
 
-	return wrappedTx{tracer: c.tracer, parent: tx}, nil
+	c.info.setInTx(true)
+	return wrappedTx{live: c.live, info: c.info, ctx: c.info.spanContext(context.Background()), parent: tx}, nil
 }
 
 func (c wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.Tx, err error) {
-	ctx, span := c.tracer.Start(ctx, "sql-tx-begin")
-	span.SetAttribute("component", "database/sql")
+	// The commit or rollback of the transaction is a sibling of its begin,
+	// under the context it was begun with.
+	txCtx := c.info.spanContext(ctx)
+	cfg := c.cfg()
+	ctx, span := cfg.startSpan(txCtx, "sql-tx-begin", c.info.spanOption(), trace.WithAttributes(
+		txIsolationLbl.String(sql.IsolationLevel(opts.Isolation).String()),
+		txReadOnlyLbl.Bool(opts.ReadOnly),
+	))
 	defer func() {
 		if err != nil {
-			span.RecordError(ctx, err)
+			cfg.recordError(ctx, span, err)
 		}
-		span.End()
+		cfg.endSpan(span, err)
 	}()
 
 	if connBeginTx, ok := c.parent.(driver.ConnBeginTx); ok {
@@ -105,7 +222,8 @@ func (c wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx dri
 			return nil, err
 		}
 
-		return wrappedTx{tracer: c.tracer, ctx: ctx, parent: tx}, nil
+		c.info.setInTx(true)
+		return wrappedTx{live: c.live, info: c.info, ctx: txCtx, parent: tx}, nil
 	}
 
 	tx, err = c.parent.Begin()
@@ -113,18 +231,27 @@ func (c wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx dri
 		return nil, err
 	}
 
-	return wrappedTx{tracer: c.tracer, ctx: ctx, parent: tx}, nil
+	c.info.setInTx(true)
+	return wrappedTx{live: c.live, info: c.info, ctx: txCtx, parent: tx}, nil
 }
 
 func (c wrappedConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
-	ctx, span := c.tracer.Start(ctx, "sql-prepare")
-	span.SetAttribute("component", "database/sql")
-	span.SetAttributes(queryLbl.String(query))
+	cfg := c.cfg()
+	ctx, cancel := cfg.withTimeout(ctx)
+	defer cancel()
+
+	ctx, span := cfg.startQuerySpan(ctx, "sql-prepare", query, c.info.spanOption())
+	hash := cfg.newStmtHash(query)
+	cfg.setCallAttrs(ctx, span, query, hash, nil)
 	defer func() {
 		if err != nil {
-			span.RecordError(ctx, err)
+			cfg.recordError(ctx, span, err)
+			cfg.setQueryOnError(ctx, span, query, nil)
+		} else {
+			cfg.setParamTypes(span, stmt)
 		}
-		span.End()
+		cfg.recordPrepare(ctx, elapsed(span))
+		cfg.endSpan(span, err)
 	}()
 
 	if connPrepareCtx, ok := c.parent.(driver.ConnPrepareContext); ok {
@@ -133,35 +260,56 @@ func (c wrappedConn) PrepareContext(ctx context.Context, query string) (stmt dri
 			return nil, err
 		}
 
-		return wrappedStmt{tracer: c.tracer, ctx: ctx, parent: stmt}, nil
+		cfg.trackPrepared(c.info)
+		return c.newStmt(ctx, query, hash, stmt), nil
+	}
+
+	stmt, err = c.parent.Prepare(query)
+	if err != nil {
+		return nil, err
 	}
 
-	return c.Prepare(query)
+	cfg.trackPrepared(c.info)
+	return c.newStmt(ctx, query, hash, stmt), nil
 }
 
 func (c wrappedConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	return c.exec(context.Background(), query, args)
+}
+
+// exec runs query with the legacy Execer, wrapping the result so that its
+// spans are children of ctx.
+func (c wrappedConn) exec(ctx context.Context, query string, args []driver.Value) (driver.Result, error) {
 	if execer, ok := c.parent.(driver.Execer); ok {
 		res, err := execer.Exec(query, args)
 		if err != nil {
 			return nil, err
 		}
 
-		return wrappedResult{tracer: c.tracer, parent: res}, nil
+		return wrappedResult{live: c.live, ctx: ctx, parent: res}, nil
 	}
 
 	return nil, driver.ErrSkip
 }
 
 func (c wrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (r driver.Result, err error) {
-	ctx, span := c.tracer.Start(ctx, "sql-conn-exec")
-	span.SetAttribute("component", "database/sql")
-	span.SetAttributes(queryLbl.String(query), argsLbl.String(pretty.Sprint(args)))
+	cfg := c.cfg()
+	ctx, cancel := cfg.withTimeout(ctx)
+	defer cancel()
+
+	ctx, span := cfg.startExecSpan(ctx, "sql-conn-exec", query, c.info.spanOption(), trace.WithAttributes(preparedLbl.Bool(false)))
+	cfg.setCallAttrs(ctx, span, query, cfg.newStmtHash(query), args, cfg.appendArgsBytes(nil, span, args)...)
 
+	defer cfg.trackInFlight(ctx)()
 	defer func() {
 		if err != nil {
-			span.RecordError(ctx, err)
+			cfg.recordError(ctx, span, err)
+			cfg.setQueryOnError(ctx, span, query, args)
+		} else {
+			cfg.setResultAttrs(span, r)
+			cfg.trackSchema(c.info, query)
 		}
-		span.End()
+		cfg.endSpan(span, err)
 	}()
 
 	if execContext, ok := c.parent.(driver.ExecerContext); ok {
@@ -170,10 +318,11 @@ func (c wrappedConn) ExecContext(ctx context.Context, query string, args []drive
 			return nil, err
 		}
 
-		return wrappedResult{tracer: c.tracer, ctx: ctx, parent: res}, nil
+		return wrappedResult{live: c.live, ctx: ctx, parent: res}, nil
 	}
 
-	// Fallback implementation
+	// Fallback implementation, which can't honor ctx once the call is made.
+	span.SetAttributes(execerContextLbl.Bool(false))
 	dargs, err := namedValueToValue(args)
 	if err != nil {
 		return nil, err
@@ -185,18 +334,32 @@ func (c wrappedConn) ExecContext(ctx context.Context, query string, args []drive
 		return nil, ctx.Err()
 	}
 
-	return c.Exec(query, dargs)
+	return c.exec(ctx, query, dargs)
 }
 
 func (c wrappedConn) Ping(ctx context.Context) (err error) {
+	cfg := c.cfg()
 	if pinger, ok := c.parent.(driver.Pinger); ok {
-		ctx, span := c.tracer.Start(ctx, "sql-ping")
-		span.SetAttribute("component", "database/sql")
+		var coalesced int64
+		if pc := cfg.pingCoalescer; pc != nil {
+			var traced bool
+			if traced, coalesced = pc.admit(); !traced {
+				if err = pinger.Ping(ctx); err != nil {
+					cfg.metrics.pingFailures.Add(ctx, 1, cfg.metricLabels(ctx)...)
+				}
+				return err
+			}
+		}
+		ctx, span := cfg.startSpan(ctx, "sql-ping", c.info.spanOption())
+		if coalesced > 0 {
+			span.SetAttributes(pingsCoalescedLbl.Int64(coalesced))
+		}
 		defer func() {
 			if err != nil {
-				span.RecordError(ctx, err)
+				cfg.recordError(ctx, span, err)
 			}
-			span.End()
+			cfg.recordPing(ctx, elapsed(span), err)
+			cfg.endSpan(span, err)
 		}()
 
 		return pinger.Ping(ctx)
@@ -204,28 +367,52 @@ func (c wrappedConn) Ping(ctx context.Context) (err error) {
 	return nil
 }
 
+func (c wrappedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.parent.(driver.NamedValueChecker); ok {
+		return c.cfg().checkedNamedValue(c.info, nv, checker.CheckNamedValue(nv))
+	}
+	return driver.ErrSkip
+}
+
 func (c wrappedConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	return c.query(context.Background(), query, args)
+}
+
+// query runs query with the legacy Queryer, wrapping the rows so that their
+// spans are children of ctx.
+func (c wrappedConn) query(ctx context.Context, query string, args []driver.Value) (driver.Rows, error) {
 	if queryer, ok := c.parent.(driver.Queryer); ok {
 		rows, err := queryer.Query(query, args)
 		if err != nil {
 			return nil, err
 		}
 
-		return wrappedRows{tracer: c.tracer, parent: rows}, nil
+		return wrappedRows{live: c.live, ctx: ctx, state: &rowsState{}, parent: rows}, nil
 	}
 
 	return nil, driver.ErrSkip
 }
 
 func (c wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
-	ctx, span := c.tracer.Start(ctx, "sql-conn-query")
-	span.SetAttribute("component", "database/sql")
-	span.SetAttributes(queryLbl.String(query), argsLbl.String(pretty.Sprint(args)))
+	cfg := c.cfg()
+	ctx, cancel := cfg.withTimeout(ctx)
+	defer func() {
+		rows = cancelOnClose(rows, cancel)
+	}()
+
+	ctx, span := cfg.startQuerySpan(ctx, "sql-conn-query", query, c.info.spanOption(), trace.WithAttributes(preparedLbl.Bool(false)))
+	cfg.setCallAttrs(ctx, span, query, cfg.newStmtHash(query), args)
+	defer cfg.trackInFlight(ctx)()
 	defer func() {
 		if err != nil {
-			span.RecordError(ctx, err)
+			cfg.recordError(ctx, span, err)
+			cfg.setQueryOnError(ctx, span, query, args)
+		}
+		slow := err == nil && c.info.explain.shouldExplain(query, elapsed(span))
+		cfg.endSpan(span, err)
+		if slow {
+			c.info.explain.explainAfter(ctx, query, args)
 		}
-		span.End()
 	}()
 
 	if queryerContext, ok := c.parent.(driver.QueryerContext); ok {
@@ -234,9 +421,11 @@ func (c wrappedConn) QueryContext(ctx context.Context, query string, args []driv
 			return nil, err
 		}
 
-		return wrappedRows{tracer: c.tracer, ctx: ctx, parent: rows}, nil
+		return wrappedRows{live: c.live, ctx: ctx, state: &rowsState{}, parent: rows}, nil
 	}
 
+	// Fallback implementation, which can't honor ctx once the call is made.
+	span.SetAttributes(queryerContextLbl.Bool(false))
 	dargs, err := namedValueToValue(args)
 	if err != nil {
 		return nil, err
@@ -248,43 +437,46 @@ func (c wrappedConn) QueryContext(ctx context.Context, query string, args []driv
 		return nil, ctx.Err()
 	}
 
-	return c.Query(query, dargs)
+	return c.query(ctx, query, dargs)
 }
 
 func (t wrappedTx) Commit() (err error) {
-	ctx, span := t.tracer.Start(t.ctx, "sql-tx-commit")
-	span.SetAttribute("component", "database/sql")
+	cfg := t.cfg()
+	defer t.info.setInTx(false)
+	ctx, span := cfg.startSpan(t.ctx, "sql-tx-commit", t.info.spanOption())
 	defer func() {
 		if err != nil {
-			span.RecordError(ctx, err)
+			cfg.recordError(ctx, span, err)
 		}
-		span.End()
+		cfg.endSpan(span, err)
 	}()
 
 	return t.parent.Commit()
 }
 
 func (t wrappedTx) Rollback() (err error) {
-	ctx, span := t.tracer.Start(t.ctx, "sql-tx-rollback")
-	span.SetAttribute("component", "database/sql")
+	cfg := t.cfg()
+	defer t.info.setInTx(false)
+	ctx, span := cfg.startSpan(t.ctx, "sql-tx-rollback", t.info.spanOption())
 	defer func() {
 		if err != nil {
-			span.RecordError(ctx, err)
+			cfg.recordError(ctx, span, err)
 		}
-		span.End()
+		cfg.endSpan(span, err)
 	}()
 
 	return t.parent.Rollback()
 }
 
 func (s wrappedStmt) Close() (err error) {
-	ctx, span := s.tracer.Start(s.ctx, "sql-stmt-close")
-	span.SetAttribute("component", "database/sql")
+	cfg := s.cfg()
+	cfg.untrackPrepared(s.info, 1)
+	ctx, span := cfg.startSpan(s.ctx, "sql-stmt-close", s.info.spanOption())
 	defer func() {
 		if err != nil {
-			span.RecordError(ctx, err)
+			cfg.recordError(ctx, span, err)
 		}
-		span.End()
+		cfg.endSpan(span, err)
 	}()
 
 	return s.parent.Close()
@@ -294,53 +486,109 @@ func (s wrappedStmt) NumInput() int {
 	return s.parent.NumInput()
 }
 
+func (s wrappedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	checker, ok := s.parent.(driver.NamedValueChecker)
+	if !ok {
+		// database/sql only asks the conn when the stmt has no checker,
+		// which the wrapped stmt always has.
+		checker, ok = s.conn.(driver.NamedValueChecker)
+	}
+	if ok {
+		return s.cfg().checkedNamedValue(s.info, nv, checker.CheckNamedValue(nv))
+	}
+	return driver.ErrSkip
+}
+
+func (s wrappedStmt) ColumnConverter(idx int) driver.ValueConverter {
+	if converter, ok := s.parent.(driver.ColumnConverter); ok {
+		return converter.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
 func (s wrappedStmt) Exec(args []driver.Value) (res driver.Result, err error) {
-	ctx, span := s.tracer.Start(s.ctx, "sql-stmt-exec")
-	span.SetAttribute("component", "database/sql")
-	span.SetAttributes(queryLbl.String(s.query), argsLbl.String(pretty.Sprint(args)))
+	cfg := s.cfg()
+	ctx, span := cfg.startExecSpan(s.ctx, "sql-stmt-exec", s.query, s.info.spanOption(), trace.WithAttributes(
+		preparedLbl.Bool(true),
+		stmtExecCountLbl.Int64(s.state.use()),
+	))
+	extra := cfg.appendArgsBytes(s.appendArgCountMismatch(nil, len(args)), span, args)
+	cfg.setCallAttrs(ctx, span, s.query, s.hash, args, extra...)
+	defer cfg.trackInFlight(ctx)()
 	defer func() {
 		if err != nil {
-			span.RecordError(ctx, err)
+			cfg.recordError(ctx, span, err)
+			cfg.setQueryOnError(ctx, span, s.query, args)
+		} else {
+			cfg.setResultAttrs(span, res)
 		}
-		span.End()
+		cfg.endSpan(span, err)
 	}()
 
-	res, err = s.parent.Exec(args)
+	return s.legacyExec(s.ctx, args)
+}
+
+// legacyExec runs the statement with the legacy Exec, wrapping the result
+// so that its spans are children of ctx.
+func (s wrappedStmt) legacyExec(ctx context.Context, args []driver.Value) (driver.Result, error) {
+	res, err := s.parent.Exec(args)
 	if err != nil {
 		return nil, err
 	}
 
-	return wrappedResult{tracer: s.tracer, ctx: s.ctx, parent: res}, nil
+	return wrappedResult{live: s.live, ctx: ctx, parent: res}, nil
 }
 
 func (s wrappedStmt) Query(args []driver.Value) (rows driver.Rows, err error) {
-	ctx, span := s.tracer.Start(s.ctx, "sql-stmt-query")
-	span.SetAttribute("component", "database/sql")
-	span.SetAttributes(queryLbl.String(s.query), argsLbl.String(pretty.Sprint(args)))
+	cfg := s.cfg()
+	ctx, span := cfg.startQuerySpan(s.ctx, "sql-stmt-query", s.query, s.info.spanOption(), trace.WithAttributes(
+		preparedLbl.Bool(true),
+		stmtExecCountLbl.Int64(s.state.use()),
+	))
+	cfg.setCallAttrs(ctx, span, s.query, s.hash, args, s.appendArgCountMismatch(nil, len(args))...)
+	defer cfg.trackInFlight(ctx)()
 	defer func() {
 		if err != nil {
-			span.RecordError(ctx, err)
+			cfg.recordError(ctx, span, err)
+			cfg.setQueryOnError(ctx, span, s.query, args)
 		}
-		span.End()
+		cfg.endSpan(span, err)
 	}()
 
-	rows, err = s.parent.Query(args)
+	return s.legacyQuery(s.ctx, args)
+}
+
+// legacyQuery runs the statement with the legacy Query, wrapping the rows
+// so that their spans are children of ctx.
+func (s wrappedStmt) legacyQuery(ctx context.Context, args []driver.Value) (driver.Rows, error) {
+	rows, err := s.parent.Query(args)
 	if err != nil {
 		return nil, err
 	}
 
-	return wrappedRows{tracer: s.tracer, ctx: s.ctx, parent: rows}, nil
+	return wrappedRows{live: s.live, ctx: ctx, state: &rowsState{}, parent: rows}, nil
 }
 
 func (s wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (res driver.Result, err error) {
-	ctx, span := s.tracer.Start(s.ctx, "sql-stmt-exec")
-	span.SetAttribute("component", "database/sql")
-	span.SetAttributes(queryLbl.String(s.query), argsLbl.String(pretty.Sprint(args)))
+	cfg := s.cfg()
+	ctx, cancel := cfg.withTimeout(ctx)
+	defer cancel()
+
+	ctx, span := cfg.startExecSpan(ctx, "sql-stmt-exec", s.query, s.info.spanOption(), trace.WithAttributes(
+		preparedLbl.Bool(true),
+		stmtExecCountLbl.Int64(s.state.use()),
+	))
+	extra := cfg.appendArgsBytes(s.appendArgCountMismatch(nil, len(args)), span, args)
+	cfg.setCallAttrs(ctx, span, s.query, s.hash, args, extra...)
+	defer cfg.trackInFlight(ctx)()
 	defer func() {
 		if err != nil {
-			span.RecordError(ctx, err)
+			cfg.recordError(ctx, span, err)
+			cfg.setQueryOnError(ctx, span, s.query, args)
+		} else {
+			cfg.setResultAttrs(span, res)
 		}
-		span.End()
+		cfg.endSpan(span, err)
 	}()
 
 	if stmtExecContext, ok := s.parent.(driver.StmtExecContext); ok {
@@ -349,7 +597,7 @@ func (s wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue)
 			return nil, err
 		}
 
-		return wrappedResult{tracer: s.tracer, ctx: ctx, parent: res}, nil
+		return wrappedResult{live: s.live, ctx: ctx, parent: res}, nil
 	}
 
 	// Fallback implementation
@@ -364,18 +612,32 @@ func (s wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue)
 		return nil, ctx.Err()
 	}
 
-	return s.Exec(dargs)
+	return s.legacyExec(ctx, dargs)
 }
 
 func (s wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
-	ctx, span := s.tracer.Start(s.ctx, "sql-stmt-query")
-	span.SetAttribute("component", "database/sql")
-	span.SetAttributes(queryLbl.String(s.query), argsLbl.String(pretty.Sprint(args)))
+	cfg := s.cfg()
+	ctx, cancel := cfg.withTimeout(ctx)
+	defer func() {
+		rows = cancelOnClose(rows, cancel)
+	}()
+
+	ctx, span := cfg.startQuerySpan(ctx, "sql-stmt-query", s.query, s.info.spanOption(), trace.WithAttributes(
+		preparedLbl.Bool(true),
+		stmtExecCountLbl.Int64(s.state.use()),
+	))
+	cfg.setCallAttrs(ctx, span, s.query, s.hash, args, s.appendArgCountMismatch(nil, len(args))...)
+	defer cfg.trackInFlight(ctx)()
 	defer func() {
 		if err != nil {
-			span.RecordError(ctx, err)
+			cfg.recordError(ctx, span, err)
+			cfg.setQueryOnError(ctx, span, s.query, args)
+		}
+		slow := err == nil && s.info.explain.shouldExplain(s.query, elapsed(span))
+		cfg.endSpan(span, err)
+		if slow {
+			s.info.explain.explainAfter(ctx, s.query, args)
 		}
-		span.End()
 	}()
 
 	if stmtQueryContext, ok := s.parent.(driver.StmtQueryContext); ok {
@@ -384,7 +646,7 @@ func (s wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue)
 			return nil, err
 		}
 
-		return wrappedRows{tracer: s.tracer, ctx: ctx, parent: rows}, nil
+		return wrappedRows{live: s.live, ctx: ctx, state: &rowsState{}, parent: rows}, nil
 	}
 
 	dargs, err := namedValueToValue(args)
@@ -398,30 +660,30 @@ func (s wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue)
 		return nil, ctx.Err()
 	}
 
-	return s.Query(dargs)
+	return s.legacyQuery(ctx, dargs)
 }
 
 func (r wrappedResult) LastInsertId() (id int64, err error) {
-	ctx, span := r.tracer.Start(r.ctx, "sql-res-lastInsertId")
-	span.SetAttribute("component", "database/sql")
+	cfg := r.cfg()
+	ctx, span := cfg.startSpan(r.ctx, "sql-res-lastInsertId")
 	defer func() {
 		if err != nil {
-			span.RecordError(ctx, err)
+			cfg.recordError(ctx, span, err)
 		}
-		span.End()
+		cfg.endSpan(span, err)
 	}()
 
 	return r.parent.LastInsertId()
 }
 
 func (r wrappedResult) RowsAffected() (num int64, err error) {
-	ctx, span := r.tracer.Start(r.ctx, "sql-res-rowsAffected")
-	span.SetAttribute("component", "database/sql")
+	cfg := r.cfg()
+	ctx, span := cfg.startSpan(r.ctx, "sql-res-rowsAffected")
 	defer func() {
 		if err != nil {
-			span.RecordError(ctx, err)
+			cfg.recordError(ctx, span, err)
 		}
-		span.End()
+		cfg.endSpan(span, err)
 	}()
 
 	return r.parent.RowsAffected()
@@ -432,17 +694,77 @@ func (r wrappedRows) Columns() []string {
 }
 
 func (r wrappedRows) Close() error {
+	if r.cancel != nil {
+		defer r.cancel()
+	}
+	r.recordSuppressedSpans()
 	return r.parent.Close()
 }
 
+func (r wrappedRows) ColumnTypeDatabaseTypeName(index int) string {
+	if typer, ok := r.parent.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return typer.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r wrappedRows) ColumnTypeLength(index int) (length int64, ok bool) {
+	if typer, ok := r.parent.(driver.RowsColumnTypeLength); ok {
+		return typer.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r wrappedRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if typer, ok := r.parent.(driver.RowsColumnTypeNullable); ok {
+		return typer.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r wrappedRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if typer, ok := r.parent.(driver.RowsColumnTypePrecisionScale); ok {
+		return typer.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+func (r wrappedRows) ColumnTypeScanType(index int) reflect.Type {
+	if typer, ok := r.parent.(driver.RowsColumnTypeScanType); ok {
+		return typer.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+// Next has no context of its own: the context of the query is checked
+// when the driver returns, so a cancellation shows on the span of the row
+// being read when it happened, and only takes effect between rows for
+// drivers that don't watch the context themselves.
 func (r wrappedRows) Next(dest []driver.Value) (err error) {
-	ctx, span := r.tracer.Start(r.ctx, "sql-rows-next")
-	span.SetAttribute("component", "database/sql")
+	cfg := r.cfg()
+	if !r.state.spanNext(cfg.maxRowSpans) {
+		return r.parent.Next(dest)
+	}
+	opts, ok := cfg.sampleRowsNext(r.ctx)
+	if !ok {
+		if err = r.parent.Next(dest); err == nil {
+			r.state.markFirstRow()
+		}
+		return err
+	}
+
+	ctx, span := cfg.startSpan(r.ctx, "sql-rows-next", opts...)
 	defer func() {
-		if err != nil {
-			span.RecordError(ctx, err)
+		// io.EOF is how drivers signal there are no more rows.
+		if err != nil && err != io.EOF {
+			cfg.recordError(ctx, span, err)
+		}
+		if err == nil && r.state.markFirstRow() {
+			// Time to first row, as opposed to the time to drain the rows.
+			span.AddEvent(ctx, "db.first_row")
 		}
-		span.End()
+		r.recordCanceled(ctx, span)
+		cfg.endSpan(span, err)
 	}()
 
 	return r.parent.Next(dest)
@@ -453,7 +775,7 @@ func namedValueToValue(named []driver.NamedValue) ([]driver.Value, error) {
 	dargs := make([]driver.Value, len(named))
 	for n, param := range named {
 		if len(param.Name) > 0 {
-			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+			return nil, errors.Wrapf(ErrNamedParamsUnsupported, "argument %d (%s)", n, param.Name)
 		}
 		dargs[n] = param.Value
 	}
@@ -0,0 +1,271 @@
+package otsql
+
+import (
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokSpace tokenKind = iota
+	tokComment
+	tokWord
+	tokNumber
+	tokString
+	tokQuotedIdent
+	// tokPositional is a `?` or `$1` placeholder.
+	tokPositional
+	// tokNamed is a `:name` or `@name` placeholder.
+	tokNamed
+	tokSemicolon
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// SQLTokenizer lexes statements for the features parsing them: the verb
+// for auto-explain and read-only args capture, placeholders for
+// interpolation, and semicolons for multi-statement detection. Pick the
+// one of the dialect of the driver with WithSQLTokenizer.
+type SQLTokenizer struct {
+	// doubleQuotedStrings is whether "..." is a string rather than an
+	// identifier.
+	doubleQuotedStrings bool
+	backtickIdents      bool
+	bracketIdents       bool
+	backslashEscapes    bool
+	hashComments        bool
+	dollarQuoting       bool
+}
+
+var (
+	// GenericTokenizer covers the common syntax of the major dialects:
+	// "..." and `...` identifiers, and Postgres $tag$ strings. The default.
+	GenericTokenizer = SQLTokenizer{backtickIdents: true, dollarQuoting: true}
+	// MySQLTokenizer lexes MySQL: `...` identifiers, "..." strings,
+	// backslash escapes and # comments.
+	MySQLTokenizer = SQLTokenizer{
+		doubleQuotedStrings: true,
+		backtickIdents:      true,
+		backslashEscapes:    true,
+		hashComments:        true,
+	}
+	// PostgresTokenizer lexes Postgres: "..." identifiers, $1 placeholders
+	// and $tag$ strings.
+	PostgresTokenizer = SQLTokenizer{dollarQuoting: true}
+	// SQLServerTokenizer lexes SQL Server: "..." and [...] identifiers.
+	SQLServerTokenizer = SQLTokenizer{bracketIdents: true}
+)
+
+// tokenize splits query into tokens. It's a lenient lexer: it never fails,
+// and an unterminated string or comment extends to the end of the query.
+func (t *SQLTokenizer) tokenize(query string) []token {
+	var tokens []token
+	for i := 0; i < len(query); {
+		kind, n := t.nextToken(query[i:])
+		tokens = append(tokens, token{kind: kind, text: query[i : i+n]})
+		i += n
+	}
+	return tokens
+}
+
+// nextToken returns the kind and length of the token at the start of s,
+// which isn't empty.
+func (t *SQLTokenizer) nextToken(s string) (tokenKind, int) {
+	c := s[0]
+	switch {
+	case isSpace(c):
+		n := 1
+		for n < len(s) && isSpace(s[n]) {
+			n++
+		}
+		return tokSpace, n
+	case strings.HasPrefix(s, "--") || c == '#' && t.hashComments:
+		if end := strings.IndexByte(s, '\n'); end >= 0 {
+			return tokComment, end + 1
+		}
+		return tokComment, len(s)
+	case strings.HasPrefix(s, "/*"):
+		if end := strings.Index(s[2:], "*/"); end >= 0 {
+			return tokComment, end + 4
+		}
+		return tokComment, len(s)
+	case c == '\'' || c == '"' && t.doubleQuotedStrings:
+		return tokString, t.quotedLen(s, c)
+	case c == '"' || c == '`' && t.backtickIdents:
+		return tokQuotedIdent, quotedLen(s, c, false)
+	case c == '[' && t.bracketIdents:
+		// ]] escapes a ] within the brackets.
+		return tokQuotedIdent, quotedLen(s, ']', false)
+	case c == '$':
+		if n := digitsLen(s[1:]); n > 0 {
+			return tokPositional, n + 1
+		}
+		if !t.dollarQuoting {
+			return tokPunct, 1
+		}
+		if n, ok := dollarQuotedLen(s); ok {
+			return tokString, n
+		}
+		return tokPunct, 1
+	case c == '?':
+		return tokPositional, 1
+	case (c == ':' || c == '@') && len(s) > 1 && isIdentStart(s[1]):
+		return tokNamed, 1 + identLen(s[1:])
+	case c == ':' && strings.HasPrefix(s, "::"):
+		return tokPunct, 2
+	case c == ';':
+		return tokSemicolon, 1
+	case isDigit(c) || c == '.' && len(s) > 1 && isDigit(s[1]):
+		return tokNumber, numberLen(s)
+	case isIdentStart(c):
+		return tokWord, identLen(s)
+	default:
+		return tokPunct, 1
+	}
+}
+
+// quotedLen returns the length of the string literal quoted by q at the
+// start of s.
+func (t *SQLTokenizer) quotedLen(s string, q byte) int {
+	return quotedLen(s, q, t.backslashEscapes)
+}
+
+// quotedLen returns the length of the string quoted by q at the start of
+// s, where a doubled quote is an escaped quote, and so is a quote preceded
+// by a backslash with backslash escapes.
+func quotedLen(s string, q byte, backslashEscapes bool) int {
+	for i := 1; i < len(s); i++ {
+		if backslashEscapes && s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] != q {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == q {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(s)
+}
+
+// dollarQuotedLen returns the length of the Postgres $tag$...$tag$ string
+// at the start of s, if there's one.
+func dollarQuotedLen(s string) (int, bool) {
+	end := strings.IndexByte(s[1:], '$')
+	if end < 0 {
+		return 0, false
+	}
+	tag := s[:end+2]
+	for _, c := range []byte(tag[1 : len(tag)-1]) {
+		if !isIdentPart(c) {
+			return 0, false
+		}
+	}
+	if close := strings.Index(s[len(tag):], tag); close >= 0 {
+		return len(tag) + close + len(tag), true
+	}
+	return len(s), true
+}
+
+func numberLen(s string) int {
+	n := 0
+	for n < len(s) && (isDigit(s[n]) || s[n] == '.') {
+		n++
+	}
+	if n < len(s) && (s[n] == 'e' || s[n] == 'E') {
+		m := n + 1
+		if m < len(s) && (s[m] == '+' || s[m] == '-') {
+			m++
+		}
+		if d := digitsLen(s[m:]); d > 0 {
+			n = m + d
+		}
+	}
+	return n
+}
+
+func digitsLen(s string) int {
+	n := 0
+	for n < len(s) && isDigit(s[n]) {
+		n++
+	}
+	return n
+}
+
+func identLen(s string) int {
+	n := 0
+	for n < len(s) && isIdentPart(s[n]) {
+		n++
+	}
+	return n
+}
+
+// sqlVerb returns the upper-cased leading keyword of query, e.g. "SELECT",
+// skipping whitespace, comments and opening parentheses. It returns "" when
+// the query doesn't start with a keyword.
+func (t *SQLTokenizer) sqlVerb(query string) string {
+	for i := 0; i < len(query); {
+		kind, n := t.nextToken(query[i:])
+		switch {
+		case kind == tokSpace || kind == tokComment || query[i] == '(':
+			i += n
+		case kind == tokWord:
+			return strings.ToUpper(query[i : i+n])
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// countStatements returns the number of statements in query, separated by
+// semicolons outside of strings, identifiers and comments. Blocks with
+// semicolons of their own, e.g. PL/SQL BEGIN ... END, are over-counted.
+func (t *SQLTokenizer) countStatements(query string) int {
+	count, empty := 0, true
+	for i := 0; i < len(query); {
+		kind, n := t.nextToken(query[i:])
+		switch kind {
+		case tokSpace, tokComment:
+		case tokSemicolon:
+			if !empty {
+				count++
+			}
+			empty = true
+		default:
+			empty = false
+		}
+		i += n
+	}
+	if !empty {
+		count++
+	}
+	return count
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isIdentStart(c byte) bool {
+	return isLetter(c) || c == '_' || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '$'
+}
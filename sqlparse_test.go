@@ -0,0 +1,148 @@
+package otsql
+
+import (
+	"database/sql/driver"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// sqlFragments are the pieces random statements are assembled from, picked
+// to hit the edges of the lexer: unterminated strings and comments, quoting
+// of every dialect, placeholders and stray bytes.
+var sqlFragments = []string{
+	"SELECT", "select", "INSERT INTO", "update", "WITH", "EXPLAIN", "t", "x1",
+	" ", "\t", "\n", "(", ")", ",", "*", "=", ";", ";;", ".", "1", "1.5e3", ".5",
+	"'", "''", "'a''b'", `'a\'b'`, `"`, `"id"`, "`", "`id`", "[", "[id]",
+	"--", "-- c\n", "/*", "*/", "/* c */", "#", "# c\n",
+	"?", "$", "$1", "$$", "$tag$", "$tag$ x $tag$", ":", "::", ":name", "@", "@name",
+	"\x00", "\xff", "é", "\\",
+}
+
+func randomSQL(rng *rand.Rand) string {
+	var b strings.Builder
+	for n := rng.Intn(12); n >= 0; n-- {
+		if rng.Intn(10) == 0 {
+			b.WriteByte(byte(rng.Intn(256)))
+			continue
+		}
+		b.WriteString(sqlFragments[rng.Intn(len(sqlFragments))])
+	}
+	return b.String()
+}
+
+var tokenizers = map[string]*SQLTokenizer{
+	"generic":   &GenericTokenizer,
+	"mysql":     &MySQLTokenizer,
+	"postgres":  &PostgresTokenizer,
+	"sqlserver": &SQLServerTokenizer,
+}
+
+// TestSQLParsingProperties feeds random and malformed statements to the
+// lexer and the extractors built on it, checking they never panic and that
+// what they return is consistent with the statement.
+func TestSQLParsingProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		query := randomSQL(rng)
+		for name, tz := range tokenizers {
+			checkTokenize(t, name, tz, query)
+			checkSQLVerb(t, name, tz, query)
+			checkCountStatements(t, name, tz, query)
+			checkExtractors(t, name, tz, query)
+		}
+	}
+}
+
+func checkTokenize(t *testing.T, name string, tz *SQLTokenizer, query string) {
+	t.Helper()
+	var b strings.Builder
+	for _, tok := range tz.tokenize(query) {
+		if tok.text == "" {
+			t.Fatalf("%s: tokenize(%q) returned an empty token", name, query)
+		}
+		b.WriteString(tok.text)
+	}
+	if b.String() != query {
+		t.Fatalf("%s: tokens of %q add up to %q", name, query, b.String())
+	}
+}
+
+func checkSQLVerb(t *testing.T, name string, tz *SQLTokenizer, query string) {
+	t.Helper()
+	verb := tz.sqlVerb(query)
+	if verb == "" {
+		return
+	}
+	if verb != strings.ToUpper(verb) || !strings.Contains(strings.ToUpper(query), verb) {
+		t.Fatalf("%s: sqlVerb(%q) = %q, not a word of the statement", name, query, verb)
+	}
+	for _, tok := range tz.tokenize(query) {
+		switch {
+		case tok.kind == tokSpace || tok.kind == tokComment || tok.text == "(":
+			continue
+		case tok.kind != tokWord || strings.ToUpper(tok.text) != verb:
+			t.Fatalf("%s: sqlVerb(%q) = %q, want its leading word or nothing", name, query, verb)
+		}
+		return
+	}
+}
+
+func checkCountStatements(t *testing.T, name string, tz *SQLTokenizer, query string) {
+	t.Helper()
+	n := tz.countStatements(query)
+	semicolons, blank := 0, true
+	for _, tok := range tz.tokenize(query) {
+		switch tok.kind {
+		case tokSemicolon:
+			semicolons++
+		case tokSpace, tokComment:
+		default:
+			blank = false
+		}
+	}
+	switch {
+	case n < 0 || n > semicolons+1:
+		t.Fatalf("%s: countStatements(%q) = %d with %d semicolons", name, query, n, semicolons)
+	case blank && n != 0:
+		t.Fatalf("%s: countStatements(%q) = %d, want 0 for a blank statement", name, query, n)
+	case !blank && n == 0:
+		t.Fatalf("%s: countStatements(%q) = 0, want at least 1", name, query)
+	}
+}
+
+// checkExtractors runs the other features lexing statements, which must
+// not panic on any of them.
+func checkExtractors(t *testing.T, name string, tz *SQLTokenizer, query string) {
+	t.Helper()
+	if n := countPlaceholders(tz.tokenize(query)); n < 0 {
+		t.Fatalf("%s: countPlaceholders(%q) = %d", name, query, n)
+	}
+	if schema, ok := schemaFromStatement(tz, query); ok && schema == "" {
+		t.Fatalf("%s: schemaFromStatement(%q) found an empty schema", name, query)
+	}
+	if got := obfuscate(tz, query); len(got) > len(query) {
+		t.Fatalf("%s: obfuscate(%q) = %q, longer than the statement", name, query, got)
+	}
+	interpolate(tz, query, []driver.Value{int64(1), "a", nil})
+}
+
+func TestSQLVerb(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT 1", "SELECT"},
+		{"  /* hint */ (select 1)", "SELECT"},
+		{"-- comment\nupdate t set x = 1", "UPDATE"},
+		{"'SELECT'", ""},
+		{"?", ""},
+		{"", ""},
+		{"/* unterminated SELECT", ""},
+	}
+	for _, tt := range tests {
+		if got := GenericTokenizer.sqlVerb(tt.query); got != tt.want {
+			t.Errorf("sqlVerb(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package otsql
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/api/metric"
+	"go.opentelemetry.io/otel/api/unit"
+)
+
+const defaultPollInterval = 10 * time.Second
+
+// StatsReporter periodically polls the connection pool statistics of a
+// *sql.DB and reports them as metrics.
+//
+// The cumulative values reported by database/sql (open, in-use and idle
+// connections, wait count and wait duration) are exposed as gauges. In
+// addition, the wait count and wait duration are reported as per-interval
+// deltas so that "connections waited in the last minute" can be alerted on.
+type StatsReporter struct {
+	db       *sql.DB
+	interval time.Duration
+
+	waits    metric.Int64Counter
+	waitTime metric.Float64Counter
+
+	mu   sync.Mutex
+	last sql.DBStats
+
+	// lifecycle is that of the instrumentation owning the reporter, if any.
+	lifecycle *lifecycle
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// StatsOption configures a StatsReporter.
+type StatsOption func(*StatsReporter)
+
+// WithPollInterval sets how often the pool statistics are polled. Deltas
+// are computed between two consecutive polls.
+func WithPollInterval(d time.Duration) StatsOption {
+	return func(r *StatsReporter) {
+		if d > 0 {
+			r.interval = d
+		}
+	}
+}
+
+// NewStatsReporter creates the pool statistics instruments on meter and
+// starts polling db in the background. Call Stop to end the polling, or use
+// Instrumentation.NewStatsReporter to have the instrumentation end it.
+func NewStatsReporter(db *sql.DB, meter metric.Meter, opts ...StatsOption) (*StatsReporter, error) {
+	return newStatsReporter(db, meter, nil, opts)
+}
+
+func newStatsReporter(db *sql.DB, meter metric.Meter, lc *lifecycle, opts []StatsOption) (*StatsReporter, error) {
+	r := &StatsReporter{
+		db:        db,
+		interval:  defaultPollInterval,
+		lifecycle: lc,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	var (
+		openConns, inUse, idle, waitCount, waitDuration metric.Int64ValueObserver
+		err                                             error
+	)
+	batch := meter.NewBatchObserver(func(ctx context.Context, result metric.BatchObserverResult) {
+		stats := r.snapshot()
+		result.Observe(nil,
+			openConns.Observation(int64(stats.OpenConnections)),
+			inUse.Observation(int64(stats.InUse)),
+			idle.Observation(int64(stats.Idle)),
+			waitCount.Observation(stats.WaitCount),
+			waitDuration.Observation(stats.WaitDuration.Milliseconds()),
+		)
+	})
+	if openConns, err = batch.NewInt64ValueObserver("db.client.connections.open"); err != nil {
+		return nil, errors.Wrap(err, "creating open connections observer")
+	}
+	if inUse, err = batch.NewInt64ValueObserver("db.client.connections.in_use"); err != nil {
+		return nil, errors.Wrap(err, "creating in-use connections observer")
+	}
+	if idle, err = batch.NewInt64ValueObserver("db.client.connections.idle"); err != nil {
+		return nil, errors.Wrap(err, "creating idle connections observer")
+	}
+	if waitCount, err = batch.NewInt64ValueObserver("db.client.connections.wait_count"); err != nil {
+		return nil, errors.Wrap(err, "creating wait count observer")
+	}
+	if waitDuration, err = batch.NewInt64ValueObserver("db.client.connections.wait_duration",
+		metric.WithUnit(unit.Milliseconds)); err != nil {
+		return nil, errors.Wrap(err, "creating wait duration observer")
+	}
+	if r.waits, err = meter.NewInt64Counter("db.client.connections.waits",
+		metric.WithDescription("Connections waited for since the previous poll")); err != nil {
+		return nil, errors.Wrap(err, "creating waits counter")
+	}
+	if r.waitTime, err = meter.NewFloat64Counter("db.client.connections.wait_time",
+		metric.WithDescription("Time spent waiting for connections since the previous poll"),
+		metric.WithUnit(unit.Milliseconds)); err != nil {
+		return nil, errors.Wrap(err, "creating wait time counter")
+	}
+
+	r.last = db.Stats()
+	if lc == nil {
+		go r.run()
+	} else if !lc.goBackground(r.run) {
+		return nil, errors.New("otsql: instrumentation is shut down")
+	}
+	return r, nil
+}
+
+// Stop ends the background polling and waits for it to return.
+func (r *StatsReporter) Stop() {
+	_ = r.Shutdown(context.Background())
+}
+
+// Shutdown ends the background polling, recording the deltas since the last
+// poll, and waits for it to return or for ctx to be done. It's safe to call
+// more than once.
+func (r *StatsReporter) Shutdown(ctx context.Context) error {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *StatsReporter) run() {
+	defer close(r.done)
+	// Without an owning instrumentation, only Shutdown stops the polling.
+	var stopping <-chan struct{}
+	if r.lifecycle != nil {
+		stopping = r.lifecycle.stopping
+	}
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			r.poll(context.Background())
+			return
+		case <-stopping:
+			r.poll(context.Background())
+			return
+		case <-ticker.C:
+			r.poll(context.Background())
+		}
+	}
+}
+
+func (r *StatsReporter) poll(ctx context.Context) {
+	r.record(ctx, r.db.Stats())
+}
+
+// record records the wait count and duration stats added since the
+// previous poll.
+func (r *StatsReporter) record(ctx context.Context, stats sql.DBStats) {
+	r.mu.Lock()
+	prev := r.last
+	r.last = stats
+	r.mu.Unlock()
+
+	if delta := stats.WaitCount - prev.WaitCount; delta > 0 {
+		r.waits.Add(ctx, delta)
+	}
+	if delta := stats.WaitDuration - prev.WaitDuration; delta > 0 {
+		r.waitTime.Add(ctx, float64(delta)/float64(time.Millisecond))
+	}
+}
+
+func (r *StatsReporter) snapshot() sql.DBStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
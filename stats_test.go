@@ -0,0 +1,118 @@
+package otsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/api/metric"
+	"go.opentelemetry.io/otel/api/trace"
+	export "go.opentelemetry.io/otel/sdk/export/metric"
+	"go.opentelemetry.io/otel/sdk/export/metric/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/controller/pull"
+	"go.opentelemetry.io/otel/sdk/metric/processor/basic"
+	"go.opentelemetry.io/otel/sdk/metric/selector/simple"
+)
+
+func init() {
+	sql.Register("test-stats-deltas", fakeDriver{})
+}
+
+// fakeDriver opens no connections, the reporters only read the stats of
+// the pool.
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	return nil, driver.ErrBadConn
+}
+
+func TestInstrumentationShutdownStopsStatsReporter(t *testing.T) {
+	inst, err := Instrument("test-stats-shutdown", fakeDriver{}, trace.NoopTracer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(inst.DriverName(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	r, err := inst.NewStatsReporter(db, metric.Meter{}, WithPollInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-r.done:
+	default:
+		t.Fatal("the reporter is still polling after Shutdown")
+	}
+	// Stopping it again is a no-op.
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := inst.NewStatsReporter(db, metric.Meter{}); err == nil {
+		t.Error("started a reporter on a shut down instrumentation")
+	}
+}
+
+func TestStatsReporterRecordsDeltas(t *testing.T) {
+	ctrl := pull.New(
+		basic.New(simple.NewWithInexpensiveDistribution(), export.DeltaExporter),
+		pull.WithCachePeriod(0),
+	)
+	collect := func() map[string]float64 {
+		t.Helper()
+		if err := ctrl.Collect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		sums := make(map[string]float64)
+		err := ctrl.ForEach(export.DeltaExporter, func(rec export.Record) error {
+			if s, ok := rec.Aggregation().(aggregation.Sum); ok {
+				sum, err := s.Sum()
+				sums[rec.Descriptor().Name()] = sum.CoerceToFloat64(rec.Descriptor().NumberKind())
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sums
+	}
+
+	db, err := sql.Open("test-stats-deltas", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	r, err := NewStatsReporter(db, ctrl.Provider().Meter("test"), WithPollInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	for _, step := range []struct {
+		stats             sql.DBStats
+		waits, waitTimeMs float64
+	}{
+		{sql.DBStats{WaitCount: 10, WaitDuration: 200 * time.Millisecond}, 10, 200},
+		{sql.DBStats{WaitCount: 15, WaitDuration: 250 * time.Millisecond}, 5, 50},
+		// No new waits: nothing to add.
+		{sql.DBStats{WaitCount: 15, WaitDuration: 250 * time.Millisecond}, 0, 0},
+	} {
+		r.record(context.Background(), step.stats)
+		sums := collect()
+		if got := sums["db.client.connections.waits"]; got != step.waits {
+			t.Errorf("after %+v, waits = %v, want %v", step.stats, got, step.waits)
+		}
+		if got := sums["db.client.connections.wait_time"]; got != step.waitTimeMs {
+			t.Errorf("after %+v, wait time = %v, want %v", step.stats, got, step.waitTimeMs)
+		}
+	}
+}
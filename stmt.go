@@ -0,0 +1,81 @@
+package otsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/label"
+)
+
+var (
+	stmtExecCountLbl    = label.Key("db.statement.exec_count")
+	argCountMismatchLbl = label.Key("db.arg_count_mismatch")
+	argCountExpectedLbl = label.Key("db.arg_count.expected")
+	argCountActualLbl   = label.Key("db.arg_count.actual")
+	paramTypesLbl       = label.Key("db.statement.param_types")
+	stmtsLeakedLbl      = label.Key("db.statements.leaked")
+)
+
+// paramTyper is implemented by prepared statements that know the types the
+// server resolved for their parameters, e.g. the OIDs of a Postgres
+// statement description.
+type paramTyper interface {
+	ParamTypes() []string
+}
+
+// stmtState is the state of a wrappedStmt, shared by its copies.
+type stmtState struct {
+	execs int64
+}
+
+// newStmt wraps parent, prepared on the conn for query, whichever prepare
+// path it came from, so that the spans of its calls are children of ctx
+// and carry query and its hash.
+func (c wrappedConn) newStmt(ctx context.Context, query string, hash *stmtHash, parent driver.Stmt) wrappedStmt {
+	return wrappedStmt{
+		live:   c.live,
+		info:   c.info,
+		conn:   c.parent,
+		ctx:    ctx,
+		query:  query,
+		hash:   hash,
+		state:  &stmtState{},
+		parent: parent,
+	}
+}
+
+// use counts an exec or query of the statement and returns how many there
+// were so far, this one included.
+func (st *stmtState) use() int64 {
+	return atomic.AddInt64(&st.execs, 1)
+}
+
+// appendArgCountMismatch flags a call with n arguments when the statement
+// expects another number of them, before the driver errors out on it.
+// Statements that don't know how many they expect are skipped.
+func (s wrappedStmt) appendArgCountMismatch(attrs []label.KeyValue, n int) []label.KeyValue {
+	if expected := s.parent.NumInput(); expected >= 0 && expected != n {
+		attrs = append(attrs,
+			argCountMismatchLbl.Bool(true),
+			argCountExpectedLbl.Int(expected),
+			argCountActualLbl.Int(n),
+		)
+	}
+	return attrs
+}
+
+// setParamTypes records the parameter types of stmt, when enabled and known
+// to its driver.
+func (cfg *config) setParamTypes(span trace.Span, stmt driver.Stmt) {
+	if !cfg.paramTypes {
+		return
+	}
+	if ws, ok := stmt.(wrappedStmt); ok {
+		stmt = ws.parent
+	}
+	if pt, ok := stmt.(paramTyper); ok {
+		span.SetAttributes(paramTypesLbl.Array(pt.ParamTypes()))
+	}
+}
@@ -0,0 +1,143 @@
+package otsql_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/aybabtme/otsql/otsqltest"
+)
+
+// legacyDriver opens conns that only prepare through the legacy Prepare.
+type legacyDriver struct {
+	otsqltest.Driver
+}
+
+func (d *legacyDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	return legacyConn{conn}, err
+}
+
+type legacyConn struct {
+	driver.Conn
+}
+
+func TestStmtSpansCarryQuery(t *testing.T) {
+	const query = "UPDATE t SET name = ? WHERE id = ?"
+
+	assertQuery := func(t *testing.T, rec *otsqltest.Recorder) {
+		t.Helper()
+		spans := rec.Named("sql-stmt-exec")
+		if len(spans) != 1 {
+			t.Fatalf("got %d sql-stmt-exec spans, want 1", len(spans))
+		}
+		if got := spans[0].Attr("query"); got != query {
+			t.Errorf("query = %v, want %q", got, query)
+		}
+	}
+
+	t.Run("PrepareContext", func(t *testing.T) {
+		db, rec := openTestDB(t, &otsqltest.Driver{})
+		stmt, err := db.PrepareContext(context.Background(), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		if _, err := stmt.Exec("x", 1); err != nil {
+			t.Fatal(err)
+		}
+		assertQuery(t, rec)
+	})
+
+	t.Run("PrepareContext fallback", func(t *testing.T) {
+		db, rec := openTestDB(t, &legacyDriver{})
+		stmt, err := db.PrepareContext(context.Background(), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		if _, err := stmt.Exec("x", 1); err != nil {
+			t.Fatal(err)
+		}
+		assertQuery(t, rec)
+	})
+
+	t.Run("Prepare", func(t *testing.T) {
+		db, rec := openTestDB(t, &otsqltest.Driver{})
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		err = conn.Raw(func(driverConn interface{}) error {
+			stmt, err := driverConn.(driver.Conn).Prepare(query)
+			if err != nil {
+				return err
+			}
+			defer stmt.Close()
+			_, err = stmt.Exec([]driver.Value{"x", int64(1)})
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertQuery(t, rec)
+	})
+}
+
+// legacyStmtDriver opens conns whose stmts only implement driver.Stmt.
+type legacyStmtDriver struct {
+	otsqltest.Driver
+}
+
+func (d *legacyStmtDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	return legacyStmtConn{conn}, err
+}
+
+type legacyStmtConn struct {
+	driver.Conn
+}
+
+func (c legacyStmtConn) Prepare(query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.Prepare(query)
+	return legacyStmt{stmt}, err
+}
+
+type legacyStmt struct {
+	driver.Stmt
+}
+
+func TestLegacyStmtSpansOncePerCall(t *testing.T) {
+	db, rec := openTestDB(t, &legacyStmtDriver{})
+	stmt, err := db.PrepareContext(context.Background(), "SELECT id FROM t WHERE id = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := stmt.ExecContext(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows, err := stmt.QueryContext(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	for name, want := range map[string][]int64{
+		"sql-stmt-exec":  {1, 2},
+		"sql-stmt-query": {3},
+	} {
+		spans := rec.Named(name)
+		if len(spans) != len(want) {
+			t.Fatalf("got %d %s spans, want %d", len(spans), name, len(want))
+		}
+		for i, span := range spans {
+			if got := span.Attr("db.statement.exec_count"); got != want[i] {
+				t.Errorf("%s #%d exec_count = %v, want %d", name, i, got, want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package otsql
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// withTimeout bounds ctx by the operation timeout, if any. The returned
+// cancel func must always be called.
+func (cfg *config) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if cfg.opTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, cfg.opTimeout)
+}
+
+// cancelOnClose defers cancel until rows are closed, since drivers may
+// stop reading them once their context is done. It cancels right away when
+// there are no rows to close.
+func cancelOnClose(rows driver.Rows, cancel context.CancelFunc) driver.Rows {
+	wr, ok := rows.(wrappedRows)
+	if !ok {
+		cancel()
+		return rows
+	}
+	wr.cancel = cancel
+	return wr
+}
@@ -0,0 +1,57 @@
+package otsql_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/aybabtme/otsql/otsqltest"
+)
+
+func TestTxSpansParentedUnderBeginContext(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		driver driver.Driver
+	}{
+		{"BeginTx", &otsqltest.Driver{}},
+		{"Begin fallback", &legacyDriver{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, end := range []struct {
+				span string
+				fn   func(*sql.Tx) error
+			}{
+				{"sql-tx-commit", (*sql.Tx).Commit},
+				{"sql-tx-rollback", (*sql.Tx).Rollback},
+			} {
+				t.Run(end.span, func(t *testing.T) {
+					db, rec := openTestDB(t, tc.driver)
+					ctx, parent := rec.Tracer().Start(context.Background(), "parent")
+					tx, err := db.BeginTx(ctx, nil)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if err := end.fn(tx); err != nil {
+						t.Fatal(err)
+					}
+					parent.End()
+
+					want := parent.SpanContext()
+					for _, name := range []string{"sql-tx-begin", end.span} {
+						spans := rec.Named(name)
+						if len(spans) != 1 {
+							t.Fatalf("got %d %s spans, want 1", len(spans), name)
+						}
+						if got := spans[0].SpanContext.TraceID; got != want.TraceID {
+							t.Errorf("%s trace = %s, want %s", name, got, want.TraceID)
+						}
+						if got := spans[0].ParentSpanID; got != want.SpanID {
+							t.Errorf("%s parent = %s, want %s", name, got, want.SpanID)
+						}
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package otsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+
+	"go.opentelemetry.io/otel/label"
+)
+
+var dbVersionLbl = label.Key("db.version")
+
+// serverVersioner is implemented by connections exposing the version of
+// their server.
+type serverVersioner interface {
+	ServerVersion() string
+}
+
+// serverVersion returns the version of the server conn is connected to,
+// when enabled, from the conn itself if it exposes it, or else by running
+// the configured query on it once. Failures are ignored.
+func (cfg *config) serverVersion(conn driver.Conn) (string, bool) {
+	if cfg.serverVersionQuery == "" {
+		return "", false
+	}
+	if v, ok := conn.(serverVersioner); ok {
+		return v.ServerVersion(), true
+	}
+
+	var (
+		rows driver.Rows
+		err  error
+	)
+	switch parent := conn.(type) {
+	case driver.QueryerContext:
+		rows, err = parent.QueryContext(context.Background(), cfg.serverVersionQuery, nil)
+	case driver.Queryer:
+		rows, err = parent.Query(cfg.serverVersionQuery, nil)
+	default:
+		return "", false
+	}
+	if err != nil {
+		return "", false
+	}
+	defer rows.Close()
+
+	dest := make([]driver.Value, len(rows.Columns()))
+	if len(dest) == 0 || rows.Next(dest) == io.EOF {
+		return "", false
+	}
+	switch v := dest[0].(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	}
+	return "", false
+}